@@ -1,13 +1,13 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
 	"math"
-	"net/http"
 
 	"github.com/gorilla/websocket"
+
+	"solana-arbitrage/oracle"
 )
 
 // A structure to represent an edge in the graph (between two tokens)
@@ -33,28 +33,18 @@ func monitorSolanaAccounts(wsURL string) (*websocket.Conn, error) {
 	return conn, nil
 }
 
-// Fetch token prices from a public API like Jupiter or a similar aggregator
-func fetchTokenPrices() map[string]float64 {
-	// Example API URL
-	apiURL := "https://api.jup.ag/price/v2?ids=So11111111111111111111111111111111111111112,TokenBAddress"
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
-
-	var data map[string]map[string]map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&data)
+// fetchTokenPrices returns the USD price of the mints from priceOracle
+func fetchTokenPrices(ctx context.Context, priceOracle oracle.PriceOracle, mints []string) (map[string]float64, error) {
+	prices, err := priceOracle.Prices(ctx, mints)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to fetch token prices: %v", err)
 	}
 
-	// Parse and return token prices
-	tokenPrices := make(map[string]float64)
-	for token, details := range data["data"] {
-		tokenPrices[token] = details["price"].(float64)
+	tokenPrices := make(map[string]float64, len(prices))
+	for mint, price := range prices {
+		tokenPrices[mint], _ = price.Float64()
 	}
-	return tokenPrices
+	return tokenPrices, nil
 }
 
 // Function to detect arbitrage opportunities using Bellman-Ford
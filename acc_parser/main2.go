@@ -1,27 +1,12 @@
 package main
 
 import (
-	"encoding/binary"
 	"fmt"
 	"log"
 
-	"github.com/gagliardetto/solana-go"
 	"github.com/gorilla/websocket"
 )
 
-type TokenAccount struct {
-	IsNative    bool   `json:"isNative"`
-	Mint        string `json:"mint"`
-	Owner       string `json:"owner"`
-	State       string `json:"state"`
-	TokenAmount struct {
-		Amount         string  `json:"amount"`
-		Decimals       int     `json:"decimals"`
-		UIAmount       float64 `json:"uiAmount"`
-		UIAmountString string  `json:"uiAmountString"`
-	} `json:"tokenAmount"`
-}
-
 type ApiPoolInfoV4 struct {
 	ID            string `json:"id"`
 	BaseMint      string `json:"baseMint"`
@@ -59,38 +44,6 @@ type APIResponse struct {
 	TimeTaken float64              `json:"timeTaken"`
 }
 
-// ///////////// Decode  raw account data into the TokenAccount struct/////////////
-func (ta *TokenAccount) Decode(data []byte) error {
-	// Decode Mint (32 bytes)
-	ta.Mint = solana.PublicKeyFromBytes(data[:32]).String()
-
-	// Decode Owner (32 bytes)
-	ta.Owner = solana.PublicKeyFromBytes(data[32:64]).String()
-
-	// Decode Amount (8 bytes)
-	amountBytes := data[64:72]
-	amount := binary.LittleEndian.Uint64(amountBytes)
-	ta.TokenAmount.Amount = fmt.Sprintf("%d", amount)
-
-	// Decode State (1 byte)
-	stateByte := data[108]
-	if stateByte == 1 {
-		ta.State = "initialized"
-	} else {
-		ta.State = "uninitialized"
-	}
-
-	// Decode Decimals (typically 6 for USDC and SPL tokens)
-	ta.TokenAmount.Decimals = 6 // Hardcoded for simplicity; you might fetch dynamically based on token
-
-	// Calculate the UIAmount
-	ta.TokenAmount.UIAmount = float64(amount) / float64(1e6) // Assuming 6 decimals
-	ta.TokenAmount.UIAmountString = fmt.Sprintf("%.6f", ta.TokenAmount.UIAmount)
-
-	return nil
-
-}
-
 const wsURL = "wss://api.mainnet-beta.solana.com"                  // Solana WebSocket RPC URL
 const poolAccount = "8sLbNZoA1cfnvMJLPfp98ZLAnFSYCFApfJKMbiXNLwxj" //Raydium SOL-USDC Pool ID
 
@@ -7,6 +7,8 @@ import (
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
+
+	"solana-arbitrage/tokenaccount"
 )
 
 func solana_metadata() {
@@ -23,7 +25,7 @@ func solana_metadata() {
 	}
 
 	// Decode the account's raw data (assuming it's a token account)
-	var parsedTokenAccount TokenAccount
+	var parsedTokenAccount tokenaccount.TokenAccount
 	if err := parsedTokenAccount.Decode(accountInfo.Value.Data.GetBinary()); err != nil {
 		log.Fatalf("Failed to decode account data: %v", err)
 	}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	blockhashRefresh = 2 * time.Second
+	feeRefresh       = 10 * time.Second
+
+	// A cached blockhash older than this is refetched rather than used
+	blockhashMaxAge = 30 * time.Second
+
+	// Most accounts getRecentPrioritizationFees accepts
+	maxFeeAccounts = 128
+)
+
+// ChainCache keeps the latest blockhash and recent priority fees fresh in the
+// background so sending a transaction needs no extra round trips
+type ChainCache struct {
+	client     *rpc.Client
+	accounts   solana.PublicKeySlice // Accounts whose write locks the fees are sampled for
+	percentile float64
+
+	mu              sync.RWMutex
+	blockhash       solana.Hash
+	lastValidHeight uint64
+	fetchedAt       time.Time
+	fees            []uint64 // Sorted fees of recent slots, micro-lamports per compute unit
+}
+
+// NewChainCache creates a cache sampling fees for the given accounts
+func NewChainCache(client *rpc.Client, accounts []solana.PublicKey, percentile float64) *ChainCache {
+	if len(accounts) > maxFeeAccounts {
+		accounts = accounts[:maxFeeAccounts]
+	}
+	return &ChainCache{
+		client:     client,
+		accounts:   accounts,
+		percentile: percentile,
+	}
+}
+
+// Run refreshes the cache until ctx is done
+func (c *ChainCache) Run(ctx context.Context) {
+	if err := c.refreshBlockhash(ctx); err != nil {
+		log.Printf("Failed to refresh blockhash: %v", err)
+	}
+	if err := c.refreshFees(ctx); err != nil {
+		log.Printf("Failed to refresh priority fees: %v", err)
+	}
+
+	blockhashTicker := time.NewTicker(blockhashRefresh)
+	defer blockhashTicker.Stop()
+	feeTicker := time.NewTicker(feeRefresh)
+	defer feeTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-blockhashTicker.C:
+			if err := c.refreshBlockhash(ctx); err != nil {
+				log.Printf("Failed to refresh blockhash: %v", err)
+			}
+		case <-feeTicker.C:
+			if err := c.refreshFees(ctx); err != nil {
+				log.Printf("Failed to refresh priority fees: %v", err)
+			}
+		}
+	}
+}
+
+// refreshBlockhash fetches the latest blockhash
+func (c *ChainCache) refreshBlockhash(ctx context.Context) error {
+	latest, err := c.client.GetLatestBlockhash(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blockhash = latest.Value.Blockhash
+	c.lastValidHeight = latest.Value.LastValidBlockHeight
+	c.fetchedAt = time.Now()
+	return nil
+}
+
+// refreshFees samples the priority fees recent slots paid for our accounts
+func (c *ChainCache) refreshFees(ctx context.Context) error {
+	samples, err := c.client.GetRecentPrioritizationFees(ctx, c.accounts)
+	if err != nil {
+		return err
+	}
+
+	fees := make([]uint64, 0, len(samples))
+	for _, sample := range samples {
+		fees = append(fees, sample.PrioritizationFee)
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fees = fees
+	return nil
+}
+
+// Blockhash returns the cached blockhash and the last block height it is
+// valid for, fetching it if the cache is empty or stale
+func (c *ChainCache) Blockhash(ctx context.Context) (solana.Hash, uint64, error) {
+	c.mu.RLock()
+	blockhash, height, fetchedAt := c.blockhash, c.lastValidHeight, c.fetchedAt
+	c.mu.RUnlock()
+	if !fetchedAt.IsZero() && time.Since(fetchedAt) < blockhashMaxAge {
+		return blockhash, height, nil
+	}
+
+	if err := c.refreshBlockhash(ctx); err != nil {
+		return solana.Hash{}, 0, fmt.Errorf("failed to fetch blockhash: %v", err)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.blockhash, c.lastValidHeight, nil
+}
+
+// PriorityFee returns the configured percentile of the sampled fees, and
+// false if there are no samples yet
+func (c *ChainCache) PriorityFee() (uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.fees) == 0 {
+		return 0, false
+	}
+	index := int(math.Ceil(c.percentile/100*float64(len(c.fees)))) - 1
+	index = max(0, min(index, len(c.fees)-1))
+	return c.fees[index], true
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// PoolConfig describes a monitored pool
+type PoolConfig struct {
+	Address    string  `json:"address"`
+	Name       string  `json:"name"`
+	BaseToken  string  `json:"baseToken"`
+	QuoteToken string  `json:"quoteToken"`
+	Dex        string  `json:"dex"`
+	Fee        float64 `json:"fee"` // Swap fee as a fraction of the input
+
+	Disabled bool `json:"disabled"` // Never subscribed to or traded
+
+	// Accounts needed to build swaps through a Raydium AMM v4 pool
+	Raydium *RaydiumPoolKeys `json:"raydium,omitempty"`
+}
+
+// TokenConfig describes a token traded through the monitored pools
+type TokenConfig struct {
+	Mint     string `json:"mint"`
+	Decimals int    `json:"decimals"`
+
+	// Set by the mint inspection at startup
+	Flags []string  `json:"-"` // Risk flags the mint policy tags
+	Info  *MintInfo `json:"-"`
+}
+
+// ExecutionConfig controls turning opportunities into transactions
+type ExecutionConfig struct {
+	Enabled     bool          `json:"enabled"`
+	Wallet      string        `json:"wallet"`      // Public key paying for and signing the transactions
+	Signer      *SignerConfig `json:"signer"`      // Without a signer transactions are only simulated
+	SlippageBps uint64        `json:"slippageBps"` // Tolerated shortfall on intermediate hops
+
+	// Share of the modeled gross profit a simulation may come up short by
+	// before the transaction is rejected
+	SimulationTolerance float64 `json:"simulationTolerance"`
+
+	// How transactions are sent: "rpc" through RPCEndpoint, or "bundle" as
+	// Jito bundles paying costs.jitoTipLamports to a tip account
+	SendMode     string `json:"sendMode"`
+	JitoEndpoint string `json:"jitoEndpoint"`
+
+	// Address lookup tables compiled into v0 transactions. When managed, the
+	// wallet's own table is created if needed and extended with the accounts
+	// of every configured pool.
+	LookupTables       []string `json:"lookupTables"`
+	ManageLookupTables bool     `json:"manageLookupTables"`
+
+	// Percentile of the recent priority fees paid for the pools' accounts to
+	// bid, capped at MaxPriorityFeeMicroLamports. 0 pays the configured fee.
+	PriorityFeePercentile       float64 `json:"priorityFeePercentile"`
+	MaxPriorityFeeMicroLamports uint64  `json:"maxPriorityFeeMicroLamports"`
+
+	Jupiter JupiterConfig `json:"jupiter"`
+}
+
+// Config holds the runtime settings of the arbitrage detector
+type Config struct {
+	Pools  []PoolConfig           `json:"pools"`
+	Tokens map[string]TokenConfig `json:"tokens"` // Keyed by the token names used in Pools
+
+	// WebSocket endpoints to subscribe to. The same pools are subscribed on
+	// every endpoint and updates are deduplicated on first arrival.
+	WSEndpoints []string `json:"wsEndpoints"`
+
+	// JSON RPC endpoint used for everything besides subscriptions
+	RPCEndpoint string `json:"rpcEndpoint"`
+
+	// When set, detection only evaluates graph snapshots whose edges were all
+	// updated within SlotWindow slots of each other.
+	RequireSlotWindow bool   `json:"requireSlotWindow"`
+	SlotWindow        uint64 `json:"slotWindow"`
+
+	// Milliseconds detection waits after a graph update so that a burst of
+	// pool updates is evaluated in one pass
+	CoalesceMillis int `json:"coalesceMillis"`
+
+	// Cycle search run on every pass: "incremental" only walks bounded-depth
+	// cycles through the changed edges, "bellman-ford" runs the full search
+	// from the changed vertices and "anchored" enumerates every cycle through
+	// AnchorTokens
+	Detector     string   `json:"detector"`
+	MaxCycleHops int      `json:"maxCycleHops"`
+	AnchorTokens []string `json:"anchorTokens"`
+
+	// Pools whose reserves are worth less than MinPoolTVL whole QuoteToken
+	// are left out of detection, and rates are those of a trade worth
+	// ReferenceTradeSize rather than the spot price. 0 disables either.
+	MinPoolTVL         float64 `json:"minPoolTVL"`
+	ReferenceTradeSize float64 `json:"referenceTradeSize"`
+
+	// A cycle already reported is only reported again once its profit moved
+	// by ReportProfitChange percentage points or the report is older than
+	// ReportExpirySeconds
+	ReportProfitChange  float64 `json:"reportProfitChange"`
+	ReportExpirySeconds int     `json:"reportExpirySeconds"`
+
+	// Execution costs deducted from every opportunity. Profits are valued in
+	// QuoteToken and opportunities netting less than MinNetProfit (in UI
+	// units of QuoteToken) are dropped.
+	Costs        CostConfig `json:"costs"`
+	QuoteToken   string     `json:"quoteToken"`
+	MinNetProfit float64    `json:"minNetProfit"`
+
+	// File detected opportunities are appended to as JSON lines, if set
+	JournalPath string `json:"journalPath"`
+
+	Execution ExecutionConfig `json:"execution"`
+
+	// Limits that halt execution when they trip
+	Risk RiskConfig `json:"risk"`
+
+	// Swaps between the target tokens keeping the inventory balanced
+	Rebalance RebalanceConfig `json:"rebalance"`
+
+	// Local address of the HTTP control API, disabled if empty, and the
+	// bearer token its requests must carry. Without a token the address must
+	// be a loopback one.
+	ControlAddress string `json:"controlAddress"`
+	ControlToken   string `json:"controlToken"`
+
+	// Tokens and pools that may be traded, reloaded on SIGHUP
+	TradeLists TradeListConfig `json:"tradeLists"`
+
+	// What to do with pools trading a token whose mint carries each flag:
+	// "exclude", "tag" or "ignore"
+	MintPolicy map[string]string `json:"mintPolicy"`
+
+	// Paper trading executes opportunities against a virtual portfolio
+	// instead of sending transactions
+	Paper PaperConfig `json:"paper"`
+}
+
+// defaultConfig returns the settings used when no config file is given
+func defaultConfig() *Config {
+	return &Config{
+		Pools: []PoolConfig{
+			{
+				Address:    "8sLbNZoA1cfnvMJLPfp98ZLAnFSYCFApfJKMbiXNLwxj",
+				Name:       "USDC-SOL",
+				BaseToken:  "USDC",
+				QuoteToken: "SOL",
+				Dex:        "raydium-amm-v4",
+				Fee:        0.003,
+			},
+			{
+				Address:    "2AXXcN6oN9bBT5owwmTH53C7QHUXvhLeu718Kqt8rvY2",
+				Name:       "SOL-GRASS",
+				BaseToken:  "SOL",
+				QuoteToken: "GRASS",
+				Dex:        "raydium-amm-v4",
+				Fee:        0.003,
+			},
+		},
+		Tokens: map[string]TokenConfig{
+			"SOL":   {Mint: "So11111111111111111111111111111111111111112", Decimals: 9},
+			"USDC":  {Mint: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", Decimals: 6},
+			"GRASS": {Mint: "Grass7B4RdKfBCjTKgSqnXkqjwiGvQyFbuSCUJr3XXjs", Decimals: 9},
+		},
+		WSEndpoints:        []string{rpc.MainNetBeta_WS},
+		RPCEndpoint:        rpc.MainNetBeta_RPC,
+		CoalesceMillis:     5,
+		Detector:           "incremental",
+		MaxCycleHops:       defaultMaxCycleHops,
+		AnchorTokens:       []string{"SOL", "USDC"},
+		MinPoolTVL:         1000,
+		ReferenceTradeSize: 10,
+
+		ReportProfitChange:  0.05,
+		ReportExpirySeconds: 60,
+
+		Costs:      defaultCostConfig(),
+		QuoteToken: "USDC",
+
+		Execution: ExecutionConfig{
+			SlippageBps:         50,
+			SimulationTolerance: 0.1,
+			SendMode:            "rpc",
+			JitoEndpoint:        defaultJitoEndpoint,
+			Jupiter: JupiterConfig{
+				Endpoint:          defaultJupiterEndpoint,
+				TimeoutMillis:     2000,
+				Retries:           2,
+				MaxQuoteDeviation: 0.02,
+			},
+		},
+		Risk: RiskConfig{
+			MaxTradesPerMinute:     30,
+			MaxConsecutiveFailures: 5,
+			StatePath:              "risk-state.json",
+		},
+		Rebalance: RebalanceConfig{
+			Threshold:       0.1,
+			IntervalSeconds: 60,
+		},
+		MintPolicy: defaultMintPolicy(),
+		Paper: PaperConfig{
+			SummarySeconds: 300,
+		},
+	}
+}
+
+// loadConfig reads a JSON config file on top of the defaults
+func loadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+
+	if len(cfg.WSEndpoints) == 0 {
+		return nil, fmt.Errorf("config %s: at least one websocket endpoint is required", path)
+	}
+	endpoints := make(map[string]bool)
+	for _, endpoint := range cfg.WSEndpoints {
+		if endpoints[endpoint] {
+			return nil, fmt.Errorf("config %s: websocket endpoint %s is listed twice", path, endpoint)
+		}
+		endpoints[endpoint] = true
+	}
+
+	for _, pool := range cfg.Pools {
+		for _, token := range []string{pool.BaseToken, pool.QuoteToken} {
+			if _, ok := cfg.Tokens[token]; !ok {
+				return nil, fmt.Errorf("config %s: pool %s uses unknown token %s", path, pool.Name, token)
+			}
+		}
+	}
+
+	if _, ok := cfg.Tokens[cfg.QuoteToken]; !ok {
+		return nil, fmt.Errorf("config %s: unknown quote token %s", path, cfg.QuoteToken)
+	}
+
+	if cfg.Execution.Enabled && cfg.Execution.Wallet == "" {
+		if cfg.Execution.Signer == nil || cfg.Execution.Signer.Type == "remote" {
+			return nil, fmt.Errorf("config %s: execution needs a wallet", path)
+		}
+	}
+
+	for flag, policy := range cfg.MintPolicy {
+		switch policy {
+		case PolicyExclude, PolicyTag, PolicyIgnore:
+		default:
+			return nil, fmt.Errorf("config %s: unknown policy %q for %s", path, policy, flag)
+		}
+	}
+
+	if cfg.Risk.MaxTradeNotional < 0 || cfg.Risk.MaxTradesPerMinute < 0 || cfg.Risk.MaxDailyLoss < 0 || cfg.Risk.MaxConsecutiveFailures < 0 {
+		return nil, fmt.Errorf("config %s: risk limits cannot be negative", path)
+	}
+	for token := range cfg.Risk.MaxTokenExposure {
+		if _, ok := cfg.Tokens[token]; !ok {
+			return nil, fmt.Errorf("config %s: exposure limit of unknown token %s", path, token)
+		}
+	}
+	if cfg.ControlAddress != "" {
+		if err := checkControlAddress(cfg.ControlAddress, cfg.ControlToken); err != nil {
+			return nil, fmt.Errorf("config %s: %v", path, err)
+		}
+	}
+
+	if cfg.Rebalance.Enabled {
+		if !cfg.Execution.Enabled {
+			return nil, fmt.Errorf("config %s: rebalancing needs execution", path)
+		}
+		var sum float64
+		for token, target := range cfg.Rebalance.Targets {
+			if _, ok := cfg.Tokens[token]; !ok {
+				return nil, fmt.Errorf("config %s: rebalance target of unknown token %s", path, token)
+			}
+			if target < 0 {
+				return nil, fmt.Errorf("config %s: negative rebalance target of %s", path, token)
+			}
+			sum += target
+		}
+		if len(cfg.Rebalance.Targets) < 2 || math.Abs(sum-1) > 1e-6 {
+			return nil, fmt.Errorf("config %s: rebalance targets must cover at least two tokens and sum to 1", path)
+		}
+		if cfg.Rebalance.Threshold <= 0 || cfg.Rebalance.IntervalSeconds <= 0 {
+			return nil, fmt.Errorf("config %s: rebalance threshold and interval must be positive", path)
+		}
+	}
+
+	if _, err := NewTradeFilter(cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %v", path, err)
+	}
+
+	if cfg.Paper.Enabled && len(cfg.Paper.Balances) == 0 {
+		cfg.Paper.Balances = map[string]float64{"SOL": 10, cfg.QuoteToken: 1000}
+	}
+	for token := range cfg.Paper.Balances {
+		if _, ok := cfg.Tokens[token]; !ok {
+			return nil, fmt.Errorf("config %s: paper balance of unknown token %s", path, token)
+		}
+	}
+
+	if jupiter := cfg.Execution.Jupiter; jupiter.TimeoutMillis <= 0 || jupiter.Retries < 0 || jupiter.MaxQuoteDeviation < 0 {
+		return nil, fmt.Errorf("config %s: Jupiter timeout must be positive, retries and deviation not negative", path)
+	}
+
+	if cfg.Execution.PriorityFeePercentile < 0 || cfg.Execution.PriorityFeePercentile > 100 {
+		return nil, fmt.Errorf("config %s: priority fee percentile must be between 0 and 100", path)
+	}
+
+	switch cfg.Execution.SendMode {
+	case "rpc":
+	case "bundle":
+		if cfg.Costs.JitoTipLamports == 0 {
+			return nil, fmt.Errorf("config %s: bundle sending needs a Jito tip", path)
+		}
+	default:
+		return nil, fmt.Errorf("config %s: unknown send mode %q", path, cfg.Execution.SendMode)
+	}
+	if cfg.Execution.SendMode != "bundle" {
+		// Only bundles carry the tip, so it is not a cost of sending otherwise
+		cfg.Costs.JitoTipLamports = 0
+	}
+
+	switch cfg.Detector {
+	case "incremental", "bellman-ford":
+	case "anchored":
+		if len(cfg.AnchorTokens) == 0 {
+			return nil, fmt.Errorf("config %s: the anchored detector needs anchor tokens", path)
+		}
+	default:
+		return nil, fmt.Errorf("config %s: unknown detector %q", path, cfg.Detector)
+	}
+
+	return cfg, nil
+}
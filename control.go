@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ControlServer serves the HTTP control API used to inspect and steer the
+// running bot
+type ControlServer struct {
+	server *http.Server
+	mux    *http.ServeMux
+	token  string
+}
+
+// NewControlServer creates a control server listening on addr. With a token,
+// every request must carry it as a bearer token. Without one, only loopback
+// addresses are allowed.
+func NewControlServer(addr, token string) (*ControlServer, error) {
+	if err := checkControlAddress(addr, token); err != nil {
+		return nil, err
+	}
+	s := &ControlServer{mux: http.NewServeMux(), token: token}
+	s.server = &http.Server{Addr: addr, Handler: http.HandlerFunc(s.authorize), ReadHeaderTimeout: 5 * time.Second}
+	return s, nil
+}
+
+// checkControlAddress refuses control API addresses reachable from other
+// hosts unless requests are authenticated with a token
+func checkControlAddress(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid control address %s: %v", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("control address %s is not a loopback address, a controlToken is required", addr)
+	}
+	return nil
+}
+
+// authorize passes requests carrying the token, if one is set, to the API
+func (s *ControlServer) authorize(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		expected := []byte("Bearer " + s.token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// HandleRisk serves the risk guard's status on GET /risk and resets it on
+// POST /risk/reset
+func (s *ControlServer) HandleRisk(guard *RiskGuard) {
+	s.mux.HandleFunc("GET /risk", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, guard.Status())
+	})
+	s.mux.HandleFunc("POST /risk/reset", func(w http.ResponseWriter, r *http.Request) {
+		guard.Reset("control API " + r.RemoteAddr)
+		writeJSON(w, guard.Status())
+	})
+}
+
+// Run serves requests until ctx is done
+func (s *ControlServer) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Control API listening on %s", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Control API stopped: %v", err)
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write control API response: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Maximum number of swaps in a conversion path between two tokens
+const maxPriceHops = 3
+
+// CostConfig describes the cost of landing an arbitrage transaction
+type CostConfig struct {
+	SignatureFeeLamports     uint64            `json:"signatureFeeLamports"`
+	Signatures               uint64            `json:"signatures"`
+	BaseComputeUnits         uint64            `json:"baseComputeUnits"`         // Compute used outside the swaps
+	ComputeUnitsPerHop       map[string]uint64 `json:"computeUnitsPerHop"`       // By DEX, "default" for the rest
+	PriorityFeeMicroLamports uint64            `json:"priorityFeeMicroLamports"` // Per compute unit
+	JitoTipLamports          uint64            `json:"jitoTipLamports"`
+}
+
+// defaultCostConfig returns mainnet fees for a single signer transaction
+func defaultCostConfig() CostConfig {
+	return CostConfig{
+		SignatureFeeLamports: 5000,
+		Signatures:           1,
+		BaseComputeUnits:     20000,
+		ComputeUnitsPerHop: map[string]uint64{
+			"raydium-amm-v4": 60000,
+			"default":        120000,
+		},
+		PriorityFeeMicroLamports: 10000,
+	}
+}
+
+// ComputeUnits estimates the compute a transaction executing the cycle needs
+func (c CostConfig) ComputeUnits(dexes []string) uint64 {
+	units := c.BaseComputeUnits
+	for _, dex := range dexes {
+		if perHop, ok := c.ComputeUnitsPerHop[dex]; ok {
+			units += perHop
+		} else {
+			units += c.ComputeUnitsPerHop["default"]
+		}
+	}
+	return units
+}
+
+// CostLamports returns the fees and, in bundle send mode, the tip paid to
+// land a transaction
+func (c CostConfig) CostLamports(computeUnits uint64) uint64 {
+	priorityFee := (computeUnits*c.PriorityFeeMicroLamports + 999999) / 1000000
+	return c.SignatureFeeLamports*c.Signatures + priorityFee + c.JitoTipLamports
+}
+
+// applyCostModel deducts execution costs from an opportunity and values its
+// profits in the quote token using the graph's prices. It reports false if
+// the profit cannot be valued.
+func applyCostModel(opp *Opportunity, snap *GraphSnapshot, cfg *Config) bool {
+	nativeToken, ok := tokenByMint(cfg.Tokens, solana.SolMint.String())
+	if !ok {
+		return false
+	}
+
+	dexes := make([]string, 0, len(opp.Hops))
+	for _, hop := range opp.Hops {
+		dexes = append(dexes, hop.Dex)
+	}
+	opp.ComputeUnits = cfg.Costs.ComputeUnits(dexes)
+	opp.CostLamports = cfg.Costs.CostLamports(opp.ComputeUnits)
+
+	// Express the cost in the start token so net profit stays in one unit
+	costInStart, ok := snap.ConvertRaw(float64(opp.CostLamports), nativeToken, opp.StartToken)
+	if !ok {
+		return false
+	}
+	opp.NetProfit = opp.GrossProfit - costInStart
+
+	quote := cfg.Tokens[cfg.QuoteToken]
+	scale := math.Pow10(quote.Decimals)
+	grossQuote, ok := snap.ConvertRaw(opp.GrossProfit, opp.StartToken, cfg.QuoteToken)
+	if !ok {
+		return false
+	}
+	costQuote, ok := snap.ConvertRaw(float64(opp.CostLamports), nativeToken, cfg.QuoteToken)
+	if !ok {
+		return false
+	}
+	inputQuote, ok := snap.ConvertRaw(opp.InputAmount, opp.StartToken, cfg.QuoteToken)
+	if !ok {
+		return false
+	}
+	opp.QuoteToken = cfg.QuoteToken
+	opp.InputQuote = inputQuote / scale
+	opp.GrossProfitQuote = grossQuote / scale
+	opp.CostQuote = costQuote / scale
+	opp.NetProfitQuote = opp.GrossProfitQuote - opp.CostQuote
+	return true
+}
+
+// tokenByMint returns the configured name of a mint
+func tokenByMint(tokens map[string]TokenConfig, mint string) (string, bool) {
+	for name, token := range tokens {
+		if token.Mint == mint {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ConvertRaw converts a raw amount of one token into raw units of another at
+// the graph's mid prices, following the path with the fewest swaps and, for
+// each pair, the pool with the deepest input reserve
+func (s *GraphSnapshot) ConvertRaw(amount float64, from, to string) (float64, bool) {
+	if from == to {
+		return amount, true
+	}
+
+	// Breadth-first search for the shortest conversion path
+	rates := map[string]float64{from: 1}
+	frontier := []string{from}
+	for hop := 0; hop < maxPriceHops && len(frontier) > 0; hop++ {
+		next := make([]string, 0)
+		for _, vertex := range frontier {
+			for token, rate := range s.midRates(vertex) {
+				if _, seen := rates[token]; seen {
+					continue
+				}
+				rates[token] = rates[vertex] * rate
+				next = append(next, token)
+			}
+		}
+		if rate, ok := rates[to]; ok {
+			return amount * rate, true
+		}
+		frontier = next
+	}
+	return 0, false
+}
+
+// midRates returns the fee-free rate from a vertex to each neighbour, taken
+// from the neighbour's deepest pool. Constant product pools give it by their
+// reserves, other edges by their rate without pool and transfer fees, and are
+// only used for neighbours no pool with reserves reaches.
+func (s *GraphSnapshot) midRates(vertex string) map[string]float64 {
+	rates := make(map[string]float64)
+	depth := make(map[string]float64)
+	for _, i := range s.OutEdges(vertex) {
+		edge := s.Edges[i]
+		if _, seen := rates[edge.To]; seen && edge.ReserveIn <= depth[edge.To] {
+			continue
+		}
+		depth[edge.To] = edge.ReserveIn
+		if edge.ReserveIn > 0 && edge.ReserveOut > 0 {
+			rates[edge.To] = edge.ReserveOut / edge.ReserveIn
+		} else {
+			rates[edge.To] = edge.Rate / ((1 - edge.Fee) * (1 - edge.TransferFeeIn) * (1 - edge.TransferFeeOut))
+		}
+	}
+	return rates
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Direction of a swap through a pool
+type Direction int
+
+const (
+	BaseToQuote Direction = iota // Sell the base token for the quote token
+	QuoteToBase                  // Sell the quote token for the base token
+)
+
+func (d Direction) String() string {
+	if d == BaseToQuote {
+		return "base->quote"
+	}
+	return "quote->base"
+}
+
+// MarshalText encodes the direction by name
+func (d Direction) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Hop is one swap of a cycle through a specific pool
+type Hop struct {
+	Pool      string
+	Direction Direction
+	From      string
+	To        string
+	Rate      float64
+	Slot      uint64
+
+	// Constant product state behind Rate, in raw token units
+	ReserveIn  float64
+	ReserveOut float64
+	Fee        float64
+	Dex        string
+
+	// Share of the transfers into and out of the pool withheld as fee
+	TransferFeeIn  float64
+	TransferFeeOut float64
+}
+
+// Out returns what the hop pays out for amount in, after the pool fee and
+// the transfer fees on both sides
+func (h Hop) Out(amount float64) float64 {
+	received := amount * (1 - h.TransferFeeIn)
+	return constantProductOut(received, h.ReserveIn, h.ReserveOut, h.Fee) * (1 - h.TransferFeeOut)
+}
+
+// MidRate returns the hop's rate without pool and transfer fees, given by
+// its reserves when it has them
+func (h Hop) MidRate() float64 {
+	if h.ReserveIn > 0 && h.ReserveOut > 0 {
+		return h.ReserveOut / h.ReserveIn
+	}
+	return h.Rate / ((1 - h.Fee) * (1 - h.TransferFeeIn) * (1 - h.TransferFeeOut))
+}
+
+// Cycle is a sequence of swaps that ends in the token it starts with. Hops
+// name the exact pool used, so parallel pools for the same pair are distinct.
+type Cycle []Hop
+
+// hopFromEdge returns the swap an edge of the graph stands for
+func hopFromEdge(edge Edge) Hop {
+	return Hop{
+		Pool:      edge.Pool,
+		Direction: edge.Direction,
+		From:      edge.From,
+		To:        edge.To,
+		Rate:      edge.Rate,
+		Slot:      edge.Slot,
+
+		ReserveIn:  edge.ReserveIn,
+		ReserveOut: edge.ReserveOut,
+		Fee:        edge.Fee,
+		Dex:        edge.Dex,
+
+		TransferFeeIn:  edge.TransferFeeIn,
+		TransferFeeOut: edge.TransferFeeOut,
+	}
+}
+
+// cycleFromEdges builds a cycle from edge indices of a snapshot
+func cycleFromEdges(graph *GraphSnapshot, indices []int) Cycle {
+	cycle := make(Cycle, 0, len(indices))
+	for _, i := range indices {
+		cycle = append(cycle, hopFromEdge(graph.Edges[i]))
+	}
+	return cycle
+}
+
+// Amount returns the amount received per unit sent around the cycle
+func (c Cycle) Amount() float64 {
+	amount := 1.0
+	for _, hop := range c {
+		amount *= hop.Rate
+	}
+	return amount
+}
+
+// StartValue returns what an amount of the token hop i pays out is worth in
+// the start token, at the mid rates of the hops after it
+func (c Cycle) StartValue(i int, amount float64) float64 {
+	for _, hop := range c[i+1:] {
+		amount *= hop.MidRate()
+	}
+	return amount
+}
+
+// ProfitPercent returns the profit of one round trip in percent
+func (c Cycle) ProfitPercent() float64 {
+	return (c.Amount() - 1.0) * 100
+}
+
+// Rates returns the exchange rate of every hop
+func (c Cycle) Rates() []float64 {
+	rates := make([]float64, 0, len(c))
+	for _, hop := range c {
+		rates = append(rates, hop.Rate)
+	}
+	return rates
+}
+
+// Path returns the tokens visited, starting and ending with the same token
+func (c Cycle) Path() []string {
+	if len(c) == 0 {
+		return nil
+	}
+	path := []string{c[0].From}
+	for _, hop := range c {
+		path = append(path, hop.To)
+	}
+	return path
+}
+
+// UsesPool reports whether any hop trades through the pool
+func (c Cycle) UsesPool(pool string) bool {
+	for _, hop := range c {
+		if hop.Pool == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// TouchesPools reports whether any hop trades through one of the pools
+func (c Cycle) TouchesPools(pools map[string]time.Time) bool {
+	for _, hop := range c {
+		if _, ok := pools[hop.Pool]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hopKey identifies a hop by pool and direction
+func (h Hop) hopKey() string {
+	return h.Pool + ":" + h.Direction.String()
+}
+
+// Canonical returns the cycle rotated to start at the hop with the smallest
+// pool and direction key, so every rotation of a cycle compares equal
+func (c Cycle) Canonical() Cycle {
+	if len(c) == 0 {
+		return c
+	}
+	start := 0
+	for i, hop := range c {
+		if hop.hopKey() < c[start].hopKey() {
+			start = i
+		}
+	}
+	rotated := make(Cycle, 0, len(c))
+	rotated = append(rotated, c[start:]...)
+	rotated = append(rotated, c[:start]...)
+	return rotated
+}
+
+// Key identifies the cycle by its canonical pool sequence
+func (c Cycle) Key() string {
+	canonical := c.Canonical()
+	keys := make([]string, 0, len(canonical))
+	for _, hop := range canonical {
+		keys = append(keys, hop.hopKey())
+	}
+	return strings.Join(keys, ",")
+}
+
+// dedupeCycles drops repeats of the same pool sequence, in any rotation,
+// keeping the first occurrence
+func dedupeCycles(cycles []Cycle) []Cycle {
+	seen := make(map[string]bool)
+	unique := make([]Cycle, 0, len(cycles))
+	for _, cycle := range cycles {
+		key := cycle.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, cycle)
+	}
+	return unique
+}
+
+func (c Cycle) String() string {
+	if len(c) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(c[0].From)
+	for _, hop := range c {
+		fmt.Fprintf(&b, " -[%s %s]-> %s", hop.Pool, hop.Direction, hop.To)
+	}
+	return b.String()
+}
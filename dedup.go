@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Number of slots an update key is remembered after a newer slot was applied
+const dedupSlotWindow = 150
+
+// AccountUpdate is a single account notification received from one provider
+type AccountUpdate struct {
+	Provider string
+	Account  solana.PublicKey
+	Slot     uint64
+	Data     []byte
+	Received time.Time
+}
+
+// updateKey identifies the same account change delivered by different providers
+type updateKey struct {
+	slot uint64
+	hash [sha256.Size]byte
+}
+
+// ProviderStats counts how a provider's updates were handled
+type ProviderStats struct {
+	Received   uint64 // All updates delivered by the provider
+	Wins       uint64 // Applied updates the provider was first to deliver
+	Duplicates uint64 // Updates another provider already delivered
+	Stale      uint64 // First arrivals older than the slot already applied
+}
+
+// UpdateDeduplicator fans in account updates from redundant subscriptions
+// and lets only the first arrival of each (account, slot, data hash) through
+type UpdateDeduplicator struct {
+	mu       sync.Mutex
+	seen     map[solana.PublicKey]map[updateKey]struct{}
+	lastSlot map[solana.PublicKey]uint64
+	stats    map[string]*ProviderStats
+}
+
+// NewUpdateDeduplicator creates an empty deduplicator
+func NewUpdateDeduplicator() *UpdateDeduplicator {
+	return &UpdateDeduplicator{
+		seen:     make(map[solana.PublicKey]map[updateKey]struct{}),
+		lastSlot: make(map[solana.PublicKey]uint64),
+		stats:    make(map[string]*ProviderStats),
+	}
+}
+
+// Accept records the update and reports whether it should be applied. Only the
+// first arrival of an update is applied, and only if its slot is not older
+// than the last slot applied for the same account.
+func (d *UpdateDeduplicator) Accept(update AccountUpdate) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats, ok := d.stats[update.Provider]
+	if !ok {
+		stats = &ProviderStats{}
+		d.stats[update.Provider] = stats
+	}
+	stats.Received++
+
+	seen, ok := d.seen[update.Account]
+	if !ok {
+		seen = make(map[updateKey]struct{})
+		d.seen[update.Account] = seen
+	}
+
+	key := updateKey{slot: update.Slot, hash: sha256.Sum256(update.Data)}
+	if _, dup := seen[key]; dup {
+		stats.Duplicates++
+		return false
+	}
+	seen[key] = struct{}{}
+
+	last, applied := d.lastSlot[update.Account]
+	if applied && update.Slot < last {
+		stats.Stale++
+		return false
+	}
+	stats.Wins++
+
+	if update.Slot > last {
+		d.lastSlot[update.Account] = update.Slot
+		// Forget keys that can no longer be applied
+		for k := range seen {
+			if k.slot+dedupSlotWindow < update.Slot {
+				delete(seen, k)
+			}
+		}
+	}
+
+	return true
+}
+
+// Stats returns a copy of the per-provider counters
+func (d *UpdateDeduplicator) Stats() map[string]ProviderStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := make(map[string]ProviderStats, len(d.stats))
+	for provider, stats := range d.stats {
+		out[provider] = *stats
+	}
+	return out
+}
+
+// WinRates returns the share of unique updates each provider delivered first
+func (d *UpdateDeduplicator) WinRates() map[string]float64 {
+	stats := d.Stats()
+
+	var total uint64
+	for _, s := range stats {
+		total += s.Wins
+	}
+
+	rates := make(map[string]float64, len(stats))
+	for provider, s := range stats {
+		if total == 0 {
+			rates[provider] = 0
+			continue
+		}
+		rates[provider] = float64(s.Wins) / float64(total)
+	}
+	return rates
+}
+
+// String formats the provider win rates for logging
+func (d *UpdateDeduplicator) String() string {
+	stats := d.Stats()
+	rates := d.WinRates()
+
+	providers := make([]string, 0, len(stats))
+	for provider := range stats {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	parts := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		s := stats[provider]
+		parts = append(parts, fmt.Sprintf("%s: %.1f%% wins (%d received, %d duplicates, %d stale)",
+			provider, rates[provider]*100, s.Received, s.Duplicates, s.Stale))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+// syntheticSnapshot builds a random graph of pools between the given number of
+// tokens. Every pool contributes an edge in each direction with rates close to
+// consistent prices, so a few cycles are slightly negative. It returns the
+// snapshot and one of its pools to use as the changed pool.
+func syntheticSnapshot(vertices, edges int, seed int64) (*GraphSnapshot, string) {
+	rng := rand.New(rand.NewSource(seed))
+
+	tokens := make([]string, vertices)
+	prices := make([]float64, vertices)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("T%d", i)
+		prices[i] = math.Exp(rng.NormFloat64())
+	}
+
+	graphEdges := make([]Edge, 0, edges)
+	for p := 0; len(graphEdges)+2 <= edges; p++ {
+		a := rng.Intn(vertices)
+		b := rng.Intn(vertices - 1)
+		if b >= a {
+			b++
+		}
+		pool := fmt.Sprintf("pool%d", p)
+
+		// Price noise of up to ±0.5% against a 0.3% fee
+		rate := prices[a] / prices[b] * (1 + (rng.Float64()-0.5)*0.01) * (1 - 0.003)
+		reverse := prices[b] / prices[a] * (1 + (rng.Float64()-0.5)*0.01) * (1 - 0.003)
+
+		graphEdges = append(graphEdges,
+			Edge{From: tokens[a], To: tokens[b], Rate: rate, Weight: -math.Log(rate), Pool: pool},
+			Edge{From: tokens[b], To: tokens[a], Rate: reverse, Weight: -math.Log(reverse), Pool: pool},
+		)
+	}
+
+	return newGraphSnapshot(tokens, graphEdges, 1), graphEdges[0].Pool
+}
+
+// quietLogs discards logs for the rest of the benchmark, as cycle analysis
+// logs would dominate the timings
+func quietLogs(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func benchmarkBellmanFord(b *testing.B, edges int) {
+	quietLogs(b)
+	snap, _ := syntheticSnapshot(edges/50, edges, 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bellmanFord(snap)
+	}
+}
+
+func benchmarkIncremental(b *testing.B, edges int) {
+	quietLogs(b)
+	snap, pool := syntheticSnapshot(edges/50, edges, 1)
+	changed := map[string]time.Time{pool: time.Now()}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		incrementalCycles(snap, changed, defaultMaxCycleHops)
+	}
+}
+
+func BenchmarkBellmanFord1k(b *testing.B)  { benchmarkBellmanFord(b, 1000) }
+func BenchmarkBellmanFord10k(b *testing.B) { benchmarkBellmanFord(b, 10000) }
+func BenchmarkIncremental1k(b *testing.B)  { benchmarkIncremental(b, 1000) }
+func BenchmarkIncremental10k(b *testing.B) { benchmarkIncremental(b, 10000) }
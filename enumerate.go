@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+)
+
+// enumerateAnchoredCycles lists every simple cycle of 2 to maxHops hops that
+// starts and ends at one of the anchor tokens, ranked by profit. Parallel pools
+// between the same pair are separate hops, so each pool combination is its own
+// cycle. A cycle through several anchors is reported once, anchored at the
+// first of them in the given order.
+func enumerateAnchoredCycles(graph *GraphSnapshot, anchors []string, maxHops int) []Cycle {
+	cycles := make([]Cycle, 0)
+
+	for k, anchor := range anchors {
+		// Cycles through an earlier anchor were already listed from it
+		excluded := make(map[string]bool)
+		for _, earlier := range anchors[:k] {
+			excluded[earlier] = true
+		}
+		if excluded[anchor] {
+			continue
+		}
+
+		e := &anchoredEnumeration{
+			graph:    graph,
+			anchor:   anchor,
+			maxHops:  maxHops,
+			onPath:   map[string]bool{anchor: true},
+			excluded: excluded,
+		}
+		e.walk(anchor)
+		cycles = append(cycles, e.found...)
+	}
+
+	sort.SliceStable(cycles, func(i, j int) bool {
+		return cycles[i].Amount() > cycles[j].Amount()
+	})
+	return cycles
+}
+
+// anchoredEnumeration is the state of a depth-first walk from an anchor
+type anchoredEnumeration struct {
+	graph    *GraphSnapshot
+	anchor   string
+	maxHops  int
+	path     []int // Edge indices walked so far
+	onPath   map[string]bool
+	excluded map[string]bool
+	found    []Cycle
+}
+
+func (e *anchoredEnumeration) walk(vertex string) {
+	for _, i := range e.graph.OutEdges(vertex) {
+		edge := e.graph.Edges[i]
+		if e.excluded[edge.To] || e.usesPool(edge.Pool) {
+			continue
+		}
+
+		if edge.To == e.anchor {
+			if len(e.path) >= 1 {
+				indices := append(append([]int{}, e.path...), i)
+				e.found = append(e.found, cycleFromEdges(e.graph, indices))
+			}
+			continue
+		}
+
+		// Leave room for the edge back to the anchor
+		if len(e.path)+2 > e.maxHops || e.onPath[edge.To] {
+			continue
+		}
+
+		e.path = append(e.path, i)
+		e.onPath[edge.To] = true
+		e.walk(edge.To)
+		e.onPath[edge.To] = false
+		e.path = e.path[:len(e.path)-1]
+	}
+}
+
+// usesPool reports whether the current path already trades through a pool
+func (e *anchoredEnumeration) usesPool(pool string) bool {
+	for _, i := range e.path {
+		if e.graph.Edges[i].Pool == pool {
+			return true
+		}
+	}
+	return false
+}
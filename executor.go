@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+// Opportunities waiting for the execution worker before new ones are dropped
+const executionQueueSize = 16
+
+// Executor turns detected opportunities into transactions
+type Executor struct {
+	client    *rpc.Client
+	builder   *TransactionBuilder
+	simulator *Simulator
+	signer    Signer
+	sender    TransactionSender
+	jito      *JitoClient
+	lookups   *LookupTableManager
+	landings  *LandingTracker
+	chain     *ChainCache
+	risk      *RiskGuard
+	journal   *OpportunityJournal
+
+	// Jupiter client cross-checking hop outputs and routing fallback swaps
+	jupiter        *JupiterClient
+	crossCheck     bool
+	maxDeviation   float64
+	jupiterRouting bool
+}
+
+// NewExecutor creates an executor for the configured wallet
+func NewExecutor(ctx context.Context, cfg *Config, journal *OpportunityJournal) (*Executor, error) {
+	var signer Signer
+	var wallet solana.PublicKey
+	if cfg.Execution.Signer != nil {
+		var err error
+		if signer, err = NewSigner(*cfg.Execution.Signer, cfg.Execution.Wallet); err != nil {
+			return nil, err
+		}
+		wallet = signer.PublicKey()
+	} else {
+		var err error
+		if wallet, err = solana.PublicKeyFromBase58(cfg.Execution.Wallet); err != nil {
+			return nil, fmt.Errorf("invalid wallet %s: %v", cfg.Execution.Wallet, err)
+		}
+	}
+
+	if cfg.Paper.Enabled && signer != nil {
+		log.Printf("Paper trading: transactions are simulated but never sent")
+		signer = nil
+	}
+
+	client := rpc.New(cfg.RPCEndpoint)
+	if err := reportWalletBalances(ctx, client, wallet, cfg); err != nil {
+		log.Printf("Failed to check wallet balances: %v", err)
+	}
+	builder := NewTransactionBuilder(wallet, cfg)
+	if err := builder.LoadTokenAccounts(ctx, client); err != nil {
+		// Missing accounts are then created idempotently by every transaction
+		log.Printf("Failed to load token accounts of %s: %v", wallet, err)
+	}
+
+	// Landings are followed by subscription on the first WebSocket endpoint
+	wsClient, err := ws.Connect(ctx, cfg.WSEndpoints[0])
+	if err != nil {
+		log.Printf("Failed to connect to %s, polling transaction statuses: %v", cfg.WSEndpoints[0], err)
+		wsClient = nil
+	}
+
+	// Priority fees are sampled for the write locks of the pools we trade
+	poolAccounts := make([]solana.PublicKey, 0, len(cfg.Pools))
+	for _, pool := range cfg.Pools {
+		address, err := solana.PublicKeyFromBase58(pool.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pool address %s: %v", pool.Address, err)
+		}
+		poolAccounts = append(poolAccounts, address)
+	}
+	chain := NewChainCache(client, poolAccounts, cfg.Execution.PriorityFeePercentile)
+	go chain.Run(ctx)
+	if cfg.Execution.PriorityFeePercentile > 0 {
+		builder.SetPriorityFees(chain, cfg.Execution.MaxPriorityFeeMicroLamports)
+	}
+
+	risk, err := NewRiskGuard(cfg, journal)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Executor{
+		client:    client,
+		builder:   builder,
+		simulator: NewSimulator(client, wallet, cfg.Tokens, cfg.Execution.SimulationTolerance),
+		signer:    signer,
+		landings:  NewLandingTracker(client, wsClient, wallet, journal),
+		chain:     chain,
+		risk:      risk,
+		journal:   journal,
+	}
+
+	switch cfg.Execution.SendMode {
+	case "bundle":
+		e.jito = NewJitoClient(cfg.Execution.JitoEndpoint, 10*time.Second)
+		e.sender = &bundleSender{jito: e.jito}
+
+		tipAccounts, err := e.jito.GetTipAccounts(ctx)
+		if err != nil {
+			log.Printf("Failed to fetch Jito tip accounts, using the known ones: %v", err)
+			tipAccounts = jitoTipAccounts
+		}
+		builder.SetTip(cfg.Costs.JitoTipLamports, tipAccounts)
+	default:
+		e.sender = &rpcSender{client: client}
+	}
+
+	if len(cfg.Execution.LookupTables) > 0 || cfg.Execution.ManageLookupTables {
+		e.lookups = NewLookupTableManager(client, wallet)
+		if err := e.lookups.Load(ctx, cfg.Execution.LookupTables); err != nil {
+			return nil, err
+		}
+		builder.SetLookupTables(e.lookups)
+	}
+	if jupiter := cfg.Execution.Jupiter; jupiter.CrossCheck || jupiter.Fallback {
+		e.jupiter = NewJupiterClient(jupiter.Endpoint, time.Duration(jupiter.TimeoutMillis)*time.Millisecond, jupiter.Retries)
+		e.crossCheck = jupiter.CrossCheck
+		e.maxDeviation = jupiter.MaxQuoteDeviation
+		if jupiter.Fallback {
+			// Jupiter routes bring their own lookup tables
+			if e.lookups == nil {
+				e.lookups = NewLookupTableManager(client, wallet)
+			}
+			builder.SetJupiter(e.jupiter, e.lookups)
+			e.jupiterRouting = true
+		}
+	}
+
+	if cfg.Execution.ManageLookupTables {
+		accounts, err := builder.LookupAccounts()
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			if err := e.lookups.Sync(ctx, accounts, e.sendInstructions); err != nil {
+				log.Printf("Failed to sync lookup tables: %v", err)
+			}
+		}()
+	}
+
+	return e, nil
+}
+
+// Run executes the queued opportunities one at a time, so no two transactions
+// are built against the same wallet balances at once
+func (e *Executor) Run(ctx context.Context, queue <-chan *Opportunity) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case opp := <-queue:
+			if err := e.Execute(ctx, opp); err != nil {
+				log.Printf("Failed to execute opportunity %s: %v", opp.ID, err)
+			}
+		}
+	}
+}
+
+// Execute builds the transaction of an opportunity, simulates it and, if a
+// signer is configured, signs and submits it
+func (e *Executor) Execute(ctx context.Context, opp *Opportunity) error {
+	if e.signer == nil {
+		return e.execute(ctx, opp)
+	}
+	// Reserve before simulating so the checks account for every trade in
+	// flight, and release the reservation if the trade is not sent
+	if err := e.risk.Reserve(opp); err != nil {
+		return err
+	}
+	if err := e.execute(ctx, opp); err != nil {
+		e.risk.Release(opp)
+		return err
+	}
+	return nil
+}
+
+// execute cross-checks, builds and simulates the opportunity's transaction
+// and sends it if a signer is configured
+func (e *Executor) execute(ctx context.Context, opp *Opportunity) error {
+	if e.crossCheck {
+		if err := e.crossCheckQuotes(ctx, opp); err != nil {
+			return err
+		}
+	}
+
+	blockhash, _, err := e.chain.Blockhash(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := e.builder.Build(ctx, opp, blockhash)
+	if err != nil {
+		return err
+	}
+
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode transaction for %s: %v", opp.ID, err)
+	}
+	log.Printf("Built transaction for opportunity %s: %d instructions, %d bytes",
+		opp.ID, len(tx.Message.Instructions), len(data))
+
+	result, err := e.simulator.Simulate(ctx, tx, opp)
+	if err != nil {
+		return err
+	}
+	if e.journal != nil {
+		if err := e.journal.RecordSimulation(result); err != nil {
+			log.Printf("Failed to journal simulation: %v", err)
+		}
+	}
+	if !result.Accepted {
+		return fmt.Errorf("rejected after simulation: %s", result.Reason)
+	}
+
+	log.Printf("Simulation of %s confirmed profit %.0f (model %.0f) using %d compute units",
+		opp.ID, result.RealizedProfit, result.ExpectedProfit, result.UnitsConsumed)
+
+	if e.signer == nil {
+		log.Printf("Opportunity %s simulated only, no signer configured", opp.ID)
+		return nil
+	}
+	if err := signTransaction(ctx, e.signer, tx); err != nil {
+		return fmt.Errorf("opportunity %s: %v", opp.ID, err)
+	}
+	_, err = e.Submit(ctx, tx, opp)
+	return err
+}
+
+// crossCheckQuotes quotes every hop's input on Jupiter and rejects the
+// opportunity if a modeled output exceeds the quote by more than the allowed
+// deviation, which points at stale pool state. Jupiter aggregates the same
+// pools, so its best route should pay at least as much. Hops Jupiter cannot
+// quote are not checked.
+func (e *Executor) crossCheckQuotes(ctx context.Context, opp *Opportunity) error {
+	quotes := make([]*JupiterQuote, len(opp.Hops))
+	var wg sync.WaitGroup
+	for i, hop := range opp.Hops {
+		wg.Add(1)
+		go func(i int, hop OpportunityHop) {
+			defer wg.Done()
+			quote, err := e.jupiter.Quote(ctx, JupiterQuoteRequest{
+				InputMint:  hop.InputMint,
+				OutputMint: hop.OutputMint,
+				Amount:     uint64(math.Floor(hop.AmountIn)),
+			})
+			if err != nil {
+				log.Printf("Jupiter cross-check of %s hop %d skipped: %v", opp.ID, i+1, err)
+				return
+			}
+			quotes[i] = quote
+		}(i, hop)
+	}
+	wg.Wait()
+
+	for i, hop := range opp.Hops {
+		quote := quotes[i]
+		if quote == nil || quote.OutAmount == 0 {
+			continue
+		}
+		if deviation := hop.AmountOut/float64(quote.OutAmount) - 1; deviation > e.maxDeviation {
+			return fmt.Errorf("rejected by Jupiter cross-check: hop %d models %.0f, %.2f%% over Jupiter's %d via %s",
+				i+1, hop.AmountOut, deviation*100, quote.OutAmount, quote.Route())
+		}
+	}
+	return nil
+}
+
+// Submit sends a signed transaction with the configured sender. The
+// transaction, and its bundle if sent as one, are tracked in the background
+// until they land.
+func (e *Executor) Submit(ctx context.Context, tx *solana.Transaction, opp *Opportunity) (string, error) {
+	id, err := e.sender.Send(ctx, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit opportunity %s: %v", opp.ID, err)
+	}
+	log.Printf("Submitted opportunity %s as %s", opp.ID, id)
+
+	go func() {
+		landing := e.landings.Track(ctx, tx.Signatures[0], opp)
+		e.risk.RecordLanding(landing)
+	}()
+
+	if e.jito != nil {
+		go func() {
+			status, err := e.jito.TrackBundle(ctx, id, time.Second, 30*time.Second)
+			if err != nil {
+				log.Printf("Bundle %s for opportunity %s: %v", id, opp.ID, err)
+				return
+			}
+			log.Printf("Bundle %s for opportunity %s landed in slot %d (%s)",
+				id, opp.ID, status.Slot, status.ConfirmationStatus)
+		}()
+	}
+
+	return id, nil
+}
+
+// ExecuteSwap builds and simulates a single hop swap, such as a rebalance,
+// and, if a signer is configured and the risk limits allow it, sends it. It
+// blocks until the swap landed or was dropped, and counts its realized
+// profit towards the risk limits.
+func (e *Executor) ExecuteSwap(ctx context.Context, swap *Opportunity, minOut uint64) error {
+	if e.signer == nil {
+		_, err := e.sendSwap(ctx, swap, minOut)
+		return err
+	}
+	if err := e.risk.Reserve(swap); err != nil {
+		return err
+	}
+	tx, err := e.sendSwap(ctx, swap, minOut)
+	if err != nil {
+		e.risk.Release(swap)
+		return err
+	}
+
+	landing := e.landings.Track(ctx, tx.Signatures[0], swap)
+	e.risk.RecordLanding(landing)
+	if landing.Status != "landed" {
+		return fmt.Errorf("swap %s %s: %s", swap.ID, landing.Status, landing.Error)
+	}
+	log.Printf("Swap %s landed", swap.ID)
+	return nil
+}
+
+// sendSwap builds and simulates a swap's transaction and, if a signer is
+// configured, signs and sends it. It returns the transaction sent, nil if it
+// was only simulated.
+func (e *Executor) sendSwap(ctx context.Context, swap *Opportunity, minOut uint64) (*solana.Transaction, error) {
+	blockhash, _, err := e.chain.Blockhash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := e.builder.BuildSwap(ctx, swap, minOut, blockhash)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.simulator.Check(ctx, tx, swap.ID); err != nil {
+		return nil, err
+	}
+	if e.signer == nil {
+		log.Printf("Swap %s simulated only, no signer configured", swap.ID)
+		return nil, nil
+	}
+
+	if err := signTransaction(ctx, e.signer, tx); err != nil {
+		return nil, fmt.Errorf("swap %s: %v", swap.ID, err)
+	}
+	id, err := e.sender.Send(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit swap %s: %v", swap.ID, err)
+	}
+	log.Printf("Submitted swap %s as %s", swap.ID, id)
+	return tx, nil
+}
+
+// sendInstructions lands a maintenance transaction of the wallet, such as a
+// lookup table update
+func (e *Executor) sendInstructions(ctx context.Context, instructions []solana.Instruction) error {
+	if e.signer == nil {
+		return fmt.Errorf("no signer configured")
+	}
+
+	blockhash, _, err := e.chain.Blockhash(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := solana.NewTransaction(instructions, blockhash, solana.TransactionPayer(e.signer.PublicKey()))
+	if err != nil {
+		return fmt.Errorf("failed to compile transaction: %v", err)
+	}
+	if err := signTransaction(ctx, e.signer, tx); err != nil {
+		return err
+	}
+
+	// Sent with preflight since these are not simulated beforehand
+	signature, err := e.client.SendTransaction(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to send transaction: %v", err)
+	}
+	return confirmSignature(ctx, e.client, signature, 30*time.Second)
+}
+
+// Landings summarizes how submitted transactions landed and what they earned
+func (e *Executor) Landings() string {
+	return e.landings.String()
+}
+
+// Wallet returns the public key trading and paying for transactions
+func (e *Executor) Wallet() solana.PublicKey {
+	return e.builder.owner
+}
+
+// JupiterRouter returns the Jupiter client when swaps may be routed through
+// it, nil otherwise
+func (e *Executor) JupiterRouter() *JupiterClient {
+	if !e.jupiterRouting {
+		return nil
+	}
+	return e.jupiter
+}
+
+// Risk returns the guard limiting execution
+func (e *Executor) Risk() *RiskGuard {
+	return e.risk
+}
+
+// Rejections returns how often each kind of simulation rejection occurred
+func (e *Executor) Rejections() map[string]int {
+	return e.simulator.Rejections()
+}
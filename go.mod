@@ -2,7 +2,10 @@ module solana-arbitrage
 
 go 1.23.3
 
-require github.com/gagliardetto/solana-go v1.12.0
+require (
+	github.com/gagliardetto/solana-go v1.12.0
+	github.com/gorilla/websocket v1.5.3
+)
 
 //require github.com/ilkamo/jupiter-go v0.11.16
 
@@ -20,7 +23,6 @@ require (
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/gorilla/rpc v1.2.0 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/logrusorgru/aurora v2.0.3+incompatible // indirect
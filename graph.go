@@ -0,0 +1,268 @@
+package main
+
+import (
+	"log"
+	"math"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Graph represents the exchange rate graph for arbitrage detection. Writers
+// update it under mu and publish an immutable snapshot after every change, so
+// detection never blocks pool updates.
+type Graph struct {
+	Vertices []string
+	Edges    []Edge
+	mu       sync.Mutex
+	version  uint64
+	snapshot atomic.Pointer[GraphSnapshot]
+
+	// Pools changed since the last TakeChanges, with the time the earliest
+	// of their updates was received
+	changes map[string]time.Time
+	updated chan struct{}
+
+	// Token-2022 transfer fees deducted from the rates, if any
+	transferFees *TransferFeeSchedule
+}
+
+// Edge represents a directed edge in the exchange rate graph
+type Edge struct {
+	From   string
+	To     string
+	Weight float64 // Negative log of exchange rate
+	Rate   float64
+	Pool   string // Pool account the rate comes from
+	Slot   uint64 // Slot of the pool state the rate was computed from
+
+	Direction Direction // Which way the edge swaps through Pool
+
+	// Constant product state behind Rate, in raw token units
+	ReserveIn  float64
+	ReserveOut float64
+	Fee        float64
+	Dex        string
+
+	// Share of the transfers into and out of the pool withheld by Token-2022
+	// transfer fees
+	TransferFeeIn  float64
+	TransferFeeOut float64
+
+	// Value of both reserves in whole quote tokens, set by the liquidity filter
+	TVLQuote float64
+}
+
+// GraphSnapshot is an immutable copy of the graph. Its slices must not be
+// modified; writers always build new ones.
+type GraphSnapshot struct {
+	Vertices []string
+	Edges    []Edge
+	MinSlot  uint64 // Lowest slot of any edge
+	MaxSlot  uint64 // Highest slot of any edge
+	Version  uint64 // Incremented on every published change
+
+	outEdges map[string][]int // Edge indices by From vertex
+}
+
+// NewGraph creates an empty graph with an empty published snapshot
+func NewGraph() *Graph {
+	g := &Graph{
+		Vertices: make([]string, 0),
+		Edges:    make([]Edge, 0),
+		changes:  make(map[string]time.Time),
+		updated:  make(chan struct{}, 1),
+	}
+	g.snapshot.Store(&GraphSnapshot{})
+	return g
+}
+
+// Snapshot returns the latest published snapshot without locking
+func (g *Graph) Snapshot() *GraphSnapshot {
+	return g.snapshot.Load()
+}
+
+// Updated returns a channel signalled after snapshots are published. Several
+// publishes before the signal is received collapse into one.
+func (g *Graph) Updated() <-chan struct{} {
+	return g.updated
+}
+
+// TakeChanges returns the pools changed since the previous call
+func (g *Graph) TakeChanges() map[string]time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	changes := g.changes
+	g.changes = make(map[string]time.Time)
+	return changes
+}
+
+// SlotSpread returns the number of slots between the oldest and newest edge
+func (s *GraphSnapshot) SlotSpread() uint64 {
+	return s.MaxSlot - s.MinSlot
+}
+
+// publish stores a snapshot of the current vertices and edges and signals
+// detection. Callers hold g.mu and must have replaced, not modified, the
+// slices they changed.
+func (g *Graph) publish(pool string, received time.Time) {
+	if first, ok := g.changes[pool]; !ok || received.Before(first) {
+		g.changes[pool] = received
+	}
+
+	g.version++
+	g.snapshot.Store(newGraphSnapshot(g.Vertices, g.Edges, g.version))
+
+	select {
+	case g.updated <- struct{}{}:
+	default:
+	}
+}
+
+// newGraphSnapshot wraps vertices and edges in a snapshot, computing its slot
+// range and adjacency index
+func newGraphSnapshot(vertices []string, edges []Edge, version uint64) *GraphSnapshot {
+	snap := &GraphSnapshot{
+		Vertices: vertices,
+		Edges:    edges,
+		Version:  version,
+		outEdges: make(map[string][]int),
+	}
+	for i, edge := range edges {
+		if i == 0 || edge.Slot < snap.MinSlot {
+			snap.MinSlot = edge.Slot
+		}
+		if edge.Slot > snap.MaxSlot {
+			snap.MaxSlot = edge.Slot
+		}
+		snap.outEdges[edge.From] = append(snap.outEdges[edge.From], i)
+	}
+	return snap
+}
+
+// OutEdges returns the indices of the edges leaving a vertex
+func (s *GraphSnapshot) OutEdges(vertex string) []int {
+	return s.outEdges[vertex]
+}
+
+func updateGraphWithPoolState(graph *Graph, state *RaydiumPoolState, info PoolConfig, update AccountUpdate) {
+	pool := update.Account.String()
+	baseToken, quoteToken := info.BaseToken, info.QuoteToken
+
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+
+	// Use big.Float for precise calculations
+	baseReserve := new(big.Float).SetUint64(state.BaseReserve)
+	quoteReserve := new(big.Float).SetUint64(state.QuoteReserve)
+
+	// Calculate rates with high precision
+	baseToQuotePrice, _ := new(big.Float).Quo(quoteReserve, baseReserve).Float64()
+	quoteToBasePrice, _ := new(big.Float).Quo(baseReserve, quoteReserve).Float64()
+
+	// Apply fee with precision
+	fee := info.Fee
+	baseToQuotePrice *= (1 - fee)
+	quoteToBasePrice *= (1 - fee)
+
+	// Transfer fees are withheld on the way into and out of the pool
+	baseTransferFee := graph.transferFees.Rate(baseToken)
+	quoteTransferFee := graph.transferFees.Rate(quoteToken)
+	baseToQuotePrice *= (1 - baseTransferFee) * (1 - quoteTransferFee)
+	quoteToBasePrice *= (1 - baseTransferFee) * (1 - quoteTransferFee)
+
+	// Convert to negative log with precision check
+	baseToQuoteRate := -math.Log(baseToQuotePrice)
+	quoteToBaseRate := -math.Log(quoteToBasePrice)
+
+	// Check for invalid rates
+	if math.IsInf(baseToQuoteRate, 0) || math.IsNaN(baseToQuoteRate) ||
+		math.IsInf(quoteToBaseRate, 0) || math.IsNaN(quoteToBaseRate) {
+		log.Printf("Warning: Invalid rate calculated for %s-%s pool", baseToken, quoteToken)
+		return
+	}
+
+	log.Printf("Pool %s-%s: 1 %s = %.12f %s, 1 %s = %.12f %s",
+		baseToken, quoteToken,
+		baseToken, baseToQuotePrice, quoteToken,
+		quoteToken, quoteToBasePrice, baseToken)
+
+	// Update vertices if needed
+	for _, token := range []string{baseToken, quoteToken} {
+		if !graph.hasVertex(token) {
+			vertices := make([]string, len(graph.Vertices), len(graph.Vertices)+1)
+			copy(vertices, graph.Vertices)
+			graph.Vertices = append(vertices, token)
+			log.Printf("Added new vertex: %s", token)
+		}
+	}
+
+	// Replace the pool's edges in a fresh slice so published snapshots stay intact
+	edges := make([]Edge, 0, len(graph.Edges)+2)
+	for _, edge := range graph.Edges {
+		if edge.Pool != pool {
+			edges = append(edges, edge)
+		}
+	}
+	graph.Edges = edges
+	reserveBase := float64(state.BaseReserve)
+	reserveQuote := float64(state.QuoteReserve)
+	graph.addEdge(Edge{
+		From: baseToken, To: quoteToken, Rate: baseToQuotePrice, Pool: pool, Slot: update.Slot,
+		Direction: BaseToQuote, ReserveIn: reserveBase, ReserveOut: reserveQuote, Fee: fee, Dex: info.Dex,
+		TransferFeeIn: baseTransferFee, TransferFeeOut: quoteTransferFee,
+	})
+	graph.addEdge(Edge{
+		From: quoteToken, To: baseToken, Rate: quoteToBasePrice, Pool: pool, Slot: update.Slot,
+		Direction: QuoteToBase, ReserveIn: reserveQuote, ReserveOut: reserveBase, Fee: fee, Dex: info.Dex,
+		TransferFeeIn: quoteTransferFee, TransferFeeOut: baseTransferFee,
+	})
+
+	graph.publish(pool, update.Received)
+}
+
+func (g *Graph) hasVertex(token string) bool {
+	for _, v := range g.Vertices {
+		if v == token {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Graph) addEdge(edge Edge) {
+	// For arbitrage detection:
+	// If rate1 * rate2 * rate3 > 1 (profitable)
+	// Then ln(rate1) + ln(rate2) + ln(rate3) > 0
+	// And -ln(rate1) - ln(rate2) - ln(rate3) < 0 (negative cycle)
+	edge.Weight = -math.Log(edge.Rate)
+	g.Edges = append(g.Edges, edge)
+}
+
+// RemovePool drops the edges of a pool and publishes the change
+func (g *Graph) RemovePool(pool string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	edges := make([]Edge, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		if edge.Pool != pool {
+			edges = append(edges, edge)
+		}
+	}
+	if len(edges) == len(g.Edges) {
+		return
+	}
+	g.Edges = edges
+	g.publish(pool, time.Now())
+}
+
+// SetTransferFees makes pool updates deduct Token-2022 transfer fees from
+// the rates
+func (g *Graph) SetTransferFees(schedule *TransferFeeSchedule) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.transferFees = schedule
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Default maximum number of hops in a cycle found by the incremental search
+const defaultMaxCycleHops = 4
+
+// incrementalCycles searches for negative cycles through the edges of the
+// changed pools only. For every changed edge u -> v it walks simple paths from
+// v back to u of at most maxHops-1 further edges, so the cost depends on the
+// neighbourhood of the update rather than the size of the whole graph.
+func incrementalCycles(graph *GraphSnapshot, changed map[string]time.Time, maxHops int) []Cycle {
+	opportunities := make([]Cycle, 0)
+	if maxHops < 2 {
+		return opportunities
+	}
+
+	for i, edge := range graph.Edges {
+		if _, ok := changed[edge.Pool]; !ok {
+			continue
+		}
+
+		search := &cycleSearch{
+			graph:   graph,
+			target:  edge.From,
+			maxHops: maxHops,
+			path:    []int{i},
+			onPath:  map[string]bool{edge.From: true, edge.To: true},
+		}
+		search.walk(edge.To, edge.Weight)
+
+		for _, indices := range search.found {
+			if cycle, ok := evaluateEdgeCycle(graph, indices); ok {
+				opportunities = append(opportunities, cycle)
+			}
+		}
+	}
+
+	return opportunities
+}
+
+// cycleSearch is the state of a bounded depth-first walk back to target
+type cycleSearch struct {
+	graph   *GraphSnapshot
+	target  string
+	maxHops int
+	path    []int // Edge indices walked so far
+	onPath  map[string]bool
+	found   [][]int
+}
+
+func (s *cycleSearch) walk(vertex string, weight float64) {
+	for _, i := range s.graph.OutEdges(vertex) {
+		edge := s.graph.Edges[i]
+		if s.usesPool(edge.Pool) {
+			continue
+		}
+		total := weight + edge.Weight
+
+		if edge.To == s.target {
+			if total < 0 {
+				cycle := make([]int, len(s.path)+1)
+				copy(cycle, s.path)
+				cycle[len(s.path)] = i
+				s.found = append(s.found, cycle)
+			}
+			continue
+		}
+
+		// Leave room for the edge that closes the cycle
+		if len(s.path)+2 > s.maxHops || s.onPath[edge.To] {
+			continue
+		}
+
+		s.path = append(s.path, i)
+		s.onPath[edge.To] = true
+		s.walk(edge.To, total)
+		s.onPath[edge.To] = false
+		s.path = s.path[:len(s.path)-1]
+	}
+}
+
+// usesPool reports whether the current path already trades through a pool
+func (s *cycleSearch) usesPool(pool string) bool {
+	for _, i := range s.path {
+		if s.graph.Edges[i].Pool == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateEdgeCycle computes the profit of a cycle given as edge indices and
+// returns it if it is profitable
+func evaluateEdgeCycle(graph *GraphSnapshot, indices []int) (Cycle, bool) {
+	cycle := cycleFromEdges(graph, indices)
+	amount := cycle.Amount()
+	profitPercent := cycle.ProfitPercent()
+
+	log.Printf("Analyzing cycle: %v", cycle)
+	log.Printf("Exchange rates: %v", cycle.Rates())
+	log.Printf("Final amount: %.12f (%.2f%%)", amount, profitPercent)
+
+	if amount <= 1.0 {
+		return nil, false
+	}
+
+	log.Printf("Found profitable cycle! Profit: %.2f%%", profitPercent)
+	return cycle, true
+}
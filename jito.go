@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Default Jito block engine bundle endpoint
+const defaultJitoEndpoint = "https://mainnet.block-engine.jito.wtf/api/v1/bundles"
+
+// Mainnet Jito tip accounts, used until the block engine returns its own list
+var jitoTipAccounts = []string{
+	"96gYZGLnJYVFmbjzopPSU6QiEV5fGqZNyN9nmNhvrZU5",
+	"HFqU5x63VTqvQss8hp11i4wVV8bD44PvwucfZ2bU7gRe",
+	"Cw8CFyM9FkoMi7K7Crf6HNQqf4uEMzpKw6QNghXLvLkY",
+	"ADaUMid9yfUytqMBgopwjb2DTLSokTSzL1zt6iGPaS49",
+	"DfXygSm4jCyNCybVYYK6DwvWqjKee8pbDmJGcLWNDXjh",
+	"ADuUkR4vqLUMWXxW9gh6D6L8pMSawimctcNZ5pGwDcEt",
+	"DttWaMuVvTiduZRnguLF7jNxTgiMBZ1hyAumKUiL2KRL",
+	"3AVi9Tg9Uo68tJfuvoKvqKNWKkC5wPdSSdeBnizKZ6jT",
+}
+
+// BundleStatus is the state of a bundle reported by getBundleStatuses
+type BundleStatus struct {
+	BundleID           string          `json:"bundle_id"`
+	Transactions       []string        `json:"transactions"`
+	Slot               uint64          `json:"slot"`
+	ConfirmationStatus string          `json:"confirmation_status"`
+	Err                json.RawMessage `json:"err"`
+}
+
+// Landed reports whether the bundle reached confirmed or finalized commitment
+func (s *BundleStatus) Landed() bool {
+	return s.ConfirmationStatus == "confirmed" || s.ConfirmationStatus == "finalized"
+}
+
+// JitoClient talks to the Jito block engine JSON-RPC API
+type JitoClient struct {
+	endpoint string
+	http     *http.Client
+	nextID   atomic.Uint64
+}
+
+// NewJitoClient creates a client for the bundle endpoint of a block engine
+func NewJitoClient(endpoint string, timeout time.Duration) *JitoClient {
+	return &JitoClient{
+		endpoint: endpoint,
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+type jsonRPCRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type jsonRPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call performs a JSON-RPC request and decodes its result into out
+func (c *JitoClient) call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	body, err := json.Marshal(jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      c.nextID.Add(1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %v", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %v", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %v", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP error %d: %s", method, resp.StatusCode, string(data))
+	}
+
+	var rpcResp jsonRPCResponse
+	if err := json.Unmarshal(data, &rpcResp); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("%s: error %d: %s", method, rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	if err := json.Unmarshal(rpcResp.Result, out); err != nil {
+		return fmt.Errorf("failed to decode %s result: %v", method, err)
+	}
+	return nil
+}
+
+// SendBundle submits signed transactions as one bundle and returns its ID
+func (c *JitoClient) SendBundle(ctx context.Context, txs []*solana.Transaction) (string, error) {
+	encoded := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			return "", fmt.Errorf("failed to encode bundle transaction: %v", err)
+		}
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(data))
+	}
+
+	var bundleID string
+	params := []interface{}{encoded, map[string]string{"encoding": "base64"}}
+	if err := c.call(ctx, "sendBundle", params, &bundleID); err != nil {
+		return "", err
+	}
+	return bundleID, nil
+}
+
+// GetBundleStatuses returns the status of each bundle that landed. Bundles
+// the block engine does not know yet are missing from the result.
+func (c *JitoClient) GetBundleStatuses(ctx context.Context, bundleIDs []string) ([]BundleStatus, error) {
+	var result struct {
+		Value []*BundleStatus `json:"value"`
+	}
+	if err := c.call(ctx, "getBundleStatuses", []interface{}{bundleIDs}, &result); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]BundleStatus, 0, len(result.Value))
+	for _, status := range result.Value {
+		if status != nil {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses, nil
+}
+
+// GetTipAccounts returns the accounts tips can be paid to
+func (c *JitoClient) GetTipAccounts(ctx context.Context) ([]string, error) {
+	var accounts []string
+	if err := c.call(ctx, "getTipAccounts", []interface{}{}, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// TrackBundle polls the status of a bundle until it lands or timeout passes
+func (c *JitoClient) TrackBundle(ctx context.Context, bundleID string, interval, timeout time.Duration) (*BundleStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		statuses, err := c.GetBundleStatuses(ctx, []string{bundleID})
+		if err == nil {
+			for i := range statuses {
+				if statuses[i].BundleID == bundleID && statuses[i].Landed() {
+					return &statuses[i], nil
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("bundle %s did not land within %v", bundleID, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// randomTipAccount picks one of the tip accounts at random to spread
+// contention between them
+func randomTipAccount(accounts []string) (solana.PublicKey, error) {
+	if len(accounts) == 0 {
+		accounts = jitoTipAccounts
+	}
+	return solana.PublicKeyFromBase58(accounts[rand.Intn(len(accounts))])
+}
+
+// jitoTipInstruction transfers the tip from the payer to a tip account
+func jitoTipInstruction(payer, tipAccount solana.PublicKey, lamports uint64) solana.Instruction {
+	return system.NewTransferInstruction(lamports, payer, tipAccount).Build()
+}
+
+// TransactionSender submits signed transactions to the cluster
+type TransactionSender interface {
+	// Send submits the transaction and returns an ID to track it by
+	Send(ctx context.Context, tx *solana.Transaction) (string, error)
+}
+
+// rpcSender sends transactions through a regular RPC node
+type rpcSender struct {
+	client *rpc.Client
+}
+
+func (s *rpcSender) Send(ctx context.Context, tx *solana.Transaction) (string, error) {
+	signature, err := s.client.SendTransactionWithOpts(ctx, tx, rpc.TransactionOpts{
+		SkipPreflight: true, // Already simulated
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %v", err)
+	}
+	return signature.String(), nil
+}
+
+// bundleSender sends each transaction as a single transaction Jito bundle
+type bundleSender struct {
+	jito *JitoClient
+}
+
+func (s *bundleSender) Send(ctx context.Context, tx *solana.Transaction) (string, error) {
+	bundleID, err := s.jito.SendBundle(ctx, []*solana.Transaction{tx})
+	if err != nil {
+		return "", fmt.Errorf("failed to send bundle: %v", err)
+	}
+	return bundleID, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestJitoSendBundle(t *testing.T) {
+	payer := solana.NewWallet().PublicKey()
+	tx := testTransaction(t, payer)
+	want, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newRPCStub(t, func(method string, params json.RawMessage) any {
+		if method != "sendBundle" {
+			t.Errorf("method = %s, want sendBundle", method)
+		}
+		var args []json.RawMessage
+		if err := json.Unmarshal(params, &args); err != nil || len(args) != 2 {
+			t.Errorf("params = %s", params)
+			return &rpcStubError{Code: -32602, Message: "invalid params"}
+		}
+		var encoded []string
+		json.Unmarshal(args[0], &encoded)
+		if len(encoded) != 1 || encoded[0] != base64.StdEncoding.EncodeToString(want) {
+			t.Errorf("transactions = %v, want the base64 transaction", encoded)
+		}
+		if string(args[1]) != `{"encoding":"base64"}` {
+			t.Errorf("options = %s", args[1])
+		}
+		return "bundle-1"
+	})
+
+	client := NewJitoClient(server.URL, time.Second)
+	bundleID, err := client.SendBundle(context.Background(), []*solana.Transaction{tx})
+	if err != nil {
+		t.Fatalf("SendBundle: %v", err)
+	}
+	if bundleID != "bundle-1" {
+		t.Errorf("bundle ID = %s, want bundle-1", bundleID)
+	}
+}
+
+func TestJitoGetBundleStatuses(t *testing.T) {
+	server := newRPCStub(t, func(method string, params json.RawMessage) any {
+		return map[string]any{
+			"context": map[string]any{"slot": 10},
+			"value": []any{
+				nil,
+				map[string]any{
+					"bundle_id":           "bundle-2",
+					"transactions":        []string{"sig"},
+					"slot":                9,
+					"confirmation_status": "confirmed",
+					"err":                 map[string]any{"Ok": nil},
+				},
+			},
+		}
+	})
+
+	client := NewJitoClient(server.URL, time.Second)
+	statuses, err := client.GetBundleStatuses(context.Background(), []string{"bundle-1", "bundle-2"})
+	if err != nil {
+		t.Fatalf("GetBundleStatuses: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("got %d statuses, want the non-null one", len(statuses))
+	}
+	if statuses[0].BundleID != "bundle-2" || statuses[0].Slot != 9 || !statuses[0].Landed() {
+		t.Errorf("status = %+v", statuses[0])
+	}
+}
+
+func TestJitoErrorResponse(t *testing.T) {
+	server := newRPCStub(t, func(method string, params json.RawMessage) any {
+		return &rpcStubError{Code: -32602, Message: "bundle contains an expired blockhash"}
+	})
+
+	client := NewJitoClient(server.URL, time.Second)
+	_, err := client.GetBundleStatuses(context.Background(), []string{"bundle-1"})
+	if err == nil || !strings.Contains(err.Error(), "-32602") || !strings.Contains(err.Error(), "expired blockhash") {
+		t.Errorf("error = %v, want the JSON-RPC error", err)
+	}
+}
+
+func TestJitoTrackBundleTimeout(t *testing.T) {
+	var polls atomic.Int32
+	server := newRPCStub(t, func(method string, params json.RawMessage) any {
+		polls.Add(1)
+		return map[string]any{"context": map[string]any{"slot": 10}, "value": []any{nil}}
+	})
+
+	client := NewJitoClient(server.URL, time.Second)
+	start := time.Now()
+	status, err := client.TrackBundle(context.Background(), "bundle-1", 10*time.Millisecond, 100*time.Millisecond)
+	if err == nil || status != nil {
+		t.Fatalf("TrackBundle = %+v, %v, want a timeout", status, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("TrackBundle returned after %v", elapsed)
+	}
+	if polls.Load() < 2 {
+		t.Errorf("polled %d times, want several", polls.Load())
+	}
+}
+
+func TestAppendTip(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	tipAccount := solana.NewWallet().PublicKey()
+	b := &TransactionBuilder{owner: owner}
+
+	instructions, err := b.appendTip(nil)
+	if err != nil || len(instructions) != 0 {
+		t.Fatalf("without a tip: %d instructions, %v", len(instructions), err)
+	}
+
+	b.SetTip(5000, []string{tipAccount.String()})
+	instructions, err = b.appendTip([]solana.Instruction{jitoTipInstruction(owner, owner, 1)})
+	if err != nil {
+		t.Fatalf("appendTip: %v", err)
+	}
+	if len(instructions) != 2 {
+		t.Fatalf("got %d instructions, want the tip appended", len(instructions))
+	}
+
+	tip := instructions[1]
+	if !tip.ProgramID().Equals(solana.SystemProgramID) {
+		t.Errorf("program = %s, want the system program", tip.ProgramID())
+	}
+	accounts := tip.Accounts()
+	if len(accounts) != 2 || !accounts[0].PublicKey.Equals(owner) || !accounts[0].IsSigner ||
+		!accounts[1].PublicKey.Equals(tipAccount) || !accounts[1].IsWritable {
+		t.Errorf("accounts = %v, want owner to tip account", accounts)
+	}
+	data, err := tip.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// System transfer: instruction index 2, then the lamports
+	if len(data) != 12 || binary.LittleEndian.Uint32(data[:4]) != 2 || binary.LittleEndian.Uint64(data[4:]) != 5000 {
+		t.Errorf("data = %x, want a transfer of 5000 lamports", data)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Default Jupiter swap API endpoint
+const defaultJupiterEndpoint = "https://quote-api.jup.ag/v6"
+
+// Delay before the first retry of a failed Jupiter request, doubled after
+// every further attempt
+const jupiterRetryBackoff = 200 * time.Millisecond
+
+// JupiterConfig enables the Jupiter swap API as a cross-check of the modeled
+// hop outputs and as a router for swaps the builder cannot build itself
+type JupiterConfig struct {
+	Endpoint      string `json:"endpoint"`
+	TimeoutMillis int    `json:"timeoutMillis"` // Per request attempt
+	Retries       int    `json:"retries"`       // Extra attempts after rate limits, server and network errors
+
+	// Share a modeled hop output may exceed Jupiter's quote for the same
+	// input by before the opportunity is rejected as mispriced
+	CrossCheck        bool    `json:"crossCheck"`
+	MaxQuoteDeviation float64 `json:"maxQuoteDeviation"`
+
+	// Route hops through pools without a native swap, and rebalances, through
+	// Jupiter's swap instructions
+	Fallback bool `json:"fallback"`
+}
+
+// JupiterQuoteRequest is an exact-in quote request
+type JupiterQuoteRequest struct {
+	InputMint        string
+	OutputMint       string
+	Amount           uint64 // Raw input token units
+	SlippageBps      uint64
+	OnlyDirectRoutes bool
+}
+
+// JupiterQuote is the best route Jupiter found for a quote request. Amounts
+// are raw token units.
+type JupiterQuote struct {
+	InputMint            string             `json:"inputMint"`
+	InAmount             uint64             `json:"inAmount,string"`
+	OutputMint           string             `json:"outputMint"`
+	OutAmount            uint64             `json:"outAmount,string"`
+	OtherAmountThreshold uint64             `json:"otherAmountThreshold,string"` // Minimum output after slippage
+	SwapMode             string             `json:"swapMode"`
+	SlippageBps          uint64             `json:"slippageBps"`
+	PriceImpactPct       float64            `json:"priceImpactPct,string"`
+	RoutePlan            []JupiterRouteStep `json:"routePlan"`
+	ContextSlot          uint64             `json:"contextSlot"`
+
+	// The response as received, passed back to /swap-instructions
+	raw json.RawMessage
+}
+
+// JupiterRouteStep is one swap of a route, carrying Percent of its input
+type JupiterRouteStep struct {
+	SwapInfo struct {
+		AmmKey     string `json:"ammKey"`
+		Label      string `json:"label"`
+		InputMint  string `json:"inputMint"`
+		OutputMint string `json:"outputMint"`
+		InAmount   uint64 `json:"inAmount,string"`
+		OutAmount  uint64 `json:"outAmount,string"`
+		FeeAmount  uint64 `json:"feeAmount,string"`
+		FeeMint    string `json:"feeMint"`
+	} `json:"swapInfo"`
+	Percent int `json:"percent"`
+}
+
+// UnmarshalJSON decodes a quote, keeping the raw response
+func (q *JupiterQuote) UnmarshalJSON(data []byte) error {
+	type quote JupiterQuote
+	if err := json.Unmarshal(data, (*quote)(q)); err != nil {
+		return err
+	}
+	q.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Route describes the route plan by the DEX labels of its steps
+func (q *JupiterQuote) Route() string {
+	labels := make([]string, 0, len(q.RoutePlan))
+	for _, step := range q.RoutePlan {
+		labels = append(labels, fmt.Sprintf("%s %d%%", step.SwapInfo.Label, step.Percent))
+	}
+	return strings.Join(labels, " -> ")
+}
+
+// JupiterInstruction is an instruction as /swap-instructions returns it
+type JupiterInstruction struct {
+	ProgramID string `json:"programId"`
+	Accounts  []struct {
+		Pubkey     string `json:"pubkey"`
+		IsSigner   bool   `json:"isSigner"`
+		IsWritable bool   `json:"isWritable"`
+	} `json:"accounts"`
+	Data string `json:"data"` // Base64
+}
+
+// Instruction decodes the instruction
+func (i *JupiterInstruction) Instruction() (solana.Instruction, error) {
+	programID, err := solana.PublicKeyFromBase58(i.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid program %s: %v", i.ProgramID, err)
+	}
+	accounts := make(solana.AccountMetaSlice, 0, len(i.Accounts))
+	for _, account := range i.Accounts {
+		pubKey, err := solana.PublicKeyFromBase58(account.Pubkey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account %s: %v", account.Pubkey, err)
+		}
+		accounts = append(accounts, solana.NewAccountMeta(pubKey, account.IsWritable, account.IsSigner))
+	}
+	data, err := base64.StdEncoding.DecodeString(i.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instruction data: %v", err)
+	}
+	return solana.NewInstruction(programID, accounts, data), nil
+}
+
+// JupiterSwapInstructions are the instructions executing a quote
+type JupiterSwapInstructions struct {
+	ComputeBudgetInstructions   []JupiterInstruction `json:"computeBudgetInstructions"`
+	SetupInstructions           []JupiterInstruction `json:"setupInstructions"`
+	SwapInstruction             JupiterInstruction   `json:"swapInstruction"`
+	CleanupInstruction          *JupiterInstruction  `json:"cleanupInstruction"`
+	AddressLookupTableAddresses []string             `json:"addressLookupTableAddresses"`
+}
+
+// Instructions returns the setup, swap and cleanup instructions. Compute
+// budget instructions are left out, transactions set their own.
+func (s *JupiterSwapInstructions) Instructions() ([]solana.Instruction, error) {
+	all := append([]JupiterInstruction(nil), s.SetupInstructions...)
+	all = append(all, s.SwapInstruction)
+	if s.CleanupInstruction != nil {
+		all = append(all, *s.CleanupInstruction)
+	}
+
+	instructions := make([]solana.Instruction, 0, len(all))
+	for _, ji := range all {
+		ix, err := ji.Instruction()
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, ix)
+	}
+	return instructions, nil
+}
+
+// JupiterClient talks to the Jupiter swap API
+type JupiterClient struct {
+	endpoint string
+	http     *http.Client
+	retries  int
+}
+
+// NewJupiterClient creates a client for the swap API at endpoint
+func NewJupiterClient(endpoint string, timeout time.Duration, retries int) *JupiterClient {
+	return &JupiterClient{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		http:     &http.Client{Timeout: timeout},
+		retries:  retries,
+	}
+}
+
+// Quote returns the best exact-in route for the request
+func (c *JupiterClient) Quote(ctx context.Context, request JupiterQuoteRequest) (*JupiterQuote, error) {
+	query := url.Values{}
+	query.Set("inputMint", request.InputMint)
+	query.Set("outputMint", request.OutputMint)
+	query.Set("amount", strconv.FormatUint(request.Amount, 10))
+	query.Set("slippageBps", strconv.FormatUint(request.SlippageBps, 10))
+	query.Set("swapMode", "ExactIn")
+	if request.OnlyDirectRoutes {
+		query.Set("onlyDirectRoutes", "true")
+	}
+
+	var quote JupiterQuote
+	if err := c.do(ctx, http.MethodGet, "/quote?"+query.Encode(), nil, &quote); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// SwapInstructions returns the instructions executing a quote for user.
+// SOL is neither wrapped nor unwrapped, swaps use the wrapped SOL account.
+func (c *JupiterClient) SwapInstructions(ctx context.Context, quote *JupiterQuote, user solana.PublicKey) (*JupiterSwapInstructions, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"quoteResponse":    quote.raw,
+		"userPublicKey":    user.String(),
+		"wrapAndUnwrapSol": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode swap-instructions request: %v", err)
+	}
+
+	var instructions JupiterSwapInstructions
+	if err := c.do(ctx, http.MethodPost, "/swap-instructions", body, &instructions); err != nil {
+		return nil, err
+	}
+	return &instructions, nil
+}
+
+// do performs a request, retrying rate limits, server errors and network
+// errors with exponential backoff, and decodes the response into out
+func (c *JupiterClient) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	name := strings.SplitN(path, "?", 2)[0]
+	backoff := jupiterRetryBackoff
+
+	var lastErr error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%s: %v, last error: %v", name, ctx.Err(), lastErr)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, lastErr = c.attempt(ctx, method, path, body, out)
+		if lastErr == nil || !retry {
+			return lastErr
+		}
+	}
+	return fmt.Errorf("%v (after %d attempts)", lastErr, c.retries+1)
+}
+
+// attempt performs a request once and reports whether a failure is worth
+// retrying
+func (c *JupiterClient) attempt(ctx context.Context, method, path string, body []byte, out interface{}) (bool, error) {
+	name := strings.SplitN(path, "?", 2)[0]
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s request: %v", name, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("failed to send %s request: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read %s response: %v", name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("%s: HTTP error %d: %s", name, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("failed to decode %s response: %v", name, err)
+	}
+	return false, nil
+}
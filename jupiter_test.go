@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	testSOLMint  = "So11111111111111111111111111111111111111112"
+	testUSDCMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+)
+
+// testQuote returns a quote response as the API sends it, amounts as strings
+func testQuote(inputMint, outputMint string, inAmount, outAmount uint64) string {
+	return fmt.Sprintf(`{
+		"inputMint": %q, "inAmount": "%d", "outputMint": %q, "outAmount": "%d",
+		"otherAmountThreshold": "%d", "swapMode": "ExactIn", "slippageBps": 50,
+		"priceImpactPct": "0.0012", "contextSlot": 300000000,
+		"routePlan": [{"swapInfo": {"ammKey": "58oQChx4yWmvKdwLLZzBi4ChoCc2fqCUWBkwMihLYQo2", "label": "Raydium",
+			"inputMint": %q, "outputMint": %q, "inAmount": "%d", "outAmount": "%d",
+			"feeAmount": "25", "feeMint": %q}, "percent": 100}]
+	}`, inputMint, inAmount, outputMint, outAmount, outAmount*995/1000,
+		inputMint, outputMint, inAmount, outAmount, inputMint)
+}
+
+// newJupiterStub serves the swap API with handle, counting requests
+func newJupiterStub(t *testing.T, handle http.HandlerFunc) (*JupiterClient, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handle(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewJupiterClient(server.URL+"/", time.Second, 2), &requests
+}
+
+func TestJupiterQuote(t *testing.T) {
+	body := testQuote(testSOLMint, testUSDCMint, 1_000_000_000, 150_000_000)
+	client, _ := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/quote" || query.Get("inputMint") != testSOLMint || query.Get("outputMint") != testUSDCMint ||
+			query.Get("amount") != "1000000000" || query.Get("slippageBps") != "50" || query.Get("swapMode") != "ExactIn" {
+			t.Errorf("request = %s", r.URL)
+		}
+		io.WriteString(w, body)
+	})
+
+	quote, err := client.Quote(context.Background(), JupiterQuoteRequest{
+		InputMint:   testSOLMint,
+		OutputMint:  testUSDCMint,
+		Amount:      1_000_000_000,
+		SlippageBps: 50,
+	})
+	if err != nil {
+		t.Fatalf("Quote: %v", err)
+	}
+	if quote.InAmount != 1_000_000_000 || quote.OutAmount != 150_000_000 || quote.OtherAmountThreshold != 149_250_000 {
+		t.Errorf("amounts = %d in, %d out, %d minimum", quote.InAmount, quote.OutAmount, quote.OtherAmountThreshold)
+	}
+	if quote.PriceImpactPct != 0.0012 || len(quote.RoutePlan) != 1 || quote.RoutePlan[0].SwapInfo.FeeAmount != 25 {
+		t.Errorf("quote = %+v", quote)
+	}
+	if route := quote.Route(); route != "Raydium 100%" {
+		t.Errorf("route = %q", route)
+	}
+	if string(quote.raw) != body {
+		t.Errorf("raw response not kept: %s", quote.raw)
+	}
+}
+
+func TestJupiterSwapInstructions(t *testing.T) {
+	user := solana.NewWallet().PublicKey()
+	program := solana.NewWallet().PublicKey()
+	quoteBody := testQuote(testSOLMint, testUSDCMint, 1000, 150)
+
+	var quote JupiterQuote
+	if err := json.Unmarshal([]byte(quoteBody), &quote); err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/swap-instructions" {
+			t.Errorf("request = %s %s", r.Method, r.URL)
+		}
+		var req struct {
+			QuoteResponse    json.RawMessage `json:"quoteResponse"`
+			UserPublicKey    string          `json:"userPublicKey"`
+			WrapAndUnwrapSol bool            `json:"wrapAndUnwrapSol"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		var sent, want any
+		json.Unmarshal(req.QuoteResponse, &sent)
+		json.Unmarshal([]byte(quoteBody), &want)
+		if fmt.Sprint(sent) != fmt.Sprint(want) {
+			t.Errorf("quoteResponse = %s, want the quote as received", req.QuoteResponse)
+		}
+		if req.UserPublicKey != user.String() || req.WrapAndUnwrapSol {
+			t.Errorf("user = %s, wrap = %v", req.UserPublicKey, req.WrapAndUnwrapSol)
+		}
+
+		instruction := fmt.Sprintf(`{"programId": %q, "accounts": [{"pubkey": %q, "isSigner": true, "isWritable": true}], "data": %q}`,
+			program, user, base64.StdEncoding.EncodeToString([]byte{1, 2, 3}))
+		fmt.Fprintf(w, `{"computeBudgetInstructions": [%s], "setupInstructions": [%s], "swapInstruction": %s,
+			"cleanupInstruction": null, "addressLookupTableAddresses": []}`, instruction, instruction, instruction)
+	})
+
+	swap, err := client.SwapInstructions(context.Background(), &quote, user)
+	if err != nil {
+		t.Fatalf("SwapInstructions: %v", err)
+	}
+	instructions, err := swap.Instructions()
+	if err != nil {
+		t.Fatalf("Instructions: %v", err)
+	}
+	if len(instructions) != 2 {
+		t.Fatalf("got %d instructions, want setup and swap without compute budget", len(instructions))
+	}
+	data, _ := instructions[1].Data()
+	accounts := instructions[1].Accounts()
+	if !instructions[1].ProgramID().Equals(program) || string(data) != "\x01\x02\x03" ||
+		len(accounts) != 1 || !accounts[0].PublicKey.Equals(user) || !accounts[0].IsSigner {
+		t.Errorf("swap instruction = %s %v %x", instructions[1].ProgramID(), accounts, data)
+	}
+}
+
+func TestJupiterRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // Responses before a successful one
+		requests int32
+		fails    bool
+	}{
+		{name: "rate limited then server error", statuses: []int{http.StatusTooManyRequests, http.StatusBadGateway}, requests: 3},
+		{name: "server errors past the retries", statuses: []int{500, 500, 500}, requests: 3, fails: true},
+		{name: "client error", statuses: []int{http.StatusBadRequest}, requests: 1, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served atomic.Int32
+			client, requests := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+				if n := int(served.Add(1)); n <= len(tt.statuses) {
+					http.Error(w, `{"error": "failed"}`, tt.statuses[n-1])
+					return
+				}
+				io.WriteString(w, testQuote(testSOLMint, testUSDCMint, 1000, 150))
+			})
+
+			_, err := client.Quote(context.Background(), JupiterQuoteRequest{InputMint: testSOLMint, OutputMint: testUSDCMint, Amount: 1000})
+			if (err != nil) != tt.fails {
+				t.Errorf("error = %v, want failure %v", err, tt.fails)
+			}
+			if requests.Load() != tt.requests {
+				t.Errorf("sent %d requests, want %d", requests.Load(), tt.requests)
+			}
+		})
+	}
+}
+
+func TestCrossCheckQuotes(t *testing.T) {
+	// Jupiter pays 150 USDC units for 1000 SOL units and 1000 SOL units for
+	// 150 USDC units
+	client, _ := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("inputMint") == testSOLMint {
+			io.WriteString(w, testQuote(testSOLMint, testUSDCMint, 1000, 150))
+			return
+		}
+		io.WriteString(w, testQuote(testUSDCMint, testSOLMint, 150, 1000))
+	})
+	e := &Executor{jupiter: client, crossCheck: true, maxDeviation: 0.02}
+
+	opportunity := func(secondOut float64) *Opportunity {
+		return &Opportunity{ID: "opp", Hops: []OpportunityHop{
+			{InputMint: testSOLMint, OutputMint: testUSDCMint, AmountIn: 1000, AmountOut: 151},
+			{InputMint: testUSDCMint, OutputMint: testSOLMint, AmountIn: 151, AmountOut: secondOut},
+		}}
+	}
+
+	if err := e.crossCheckQuotes(context.Background(), opportunity(1015)); err != nil {
+		t.Errorf("outputs within the deviation rejected: %v", err)
+	}
+	err := e.crossCheckQuotes(context.Background(), opportunity(1050))
+	if err == nil || !strings.Contains(err.Error(), "hop 2") {
+		t.Errorf("error = %v, want hop 2 rejected", err)
+	}
+}
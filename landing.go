@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+// How long a sent transaction is followed before it counts as dropped
+const landingTimeout = 60 * time.Second
+
+// Attempts at reading a confirmed transaction, and the delay before the first
+// retry, doubled after every further attempt. RPC nodes may not serve a
+// transaction right after it is confirmed.
+const (
+	reconcileAttempts = 5
+	reconcileBackoff  = 500 * time.Millisecond
+)
+
+// Landing is the on-chain outcome of a submitted opportunity. Profits are in
+// UI units of the quote token, valued at the prices of detection.
+type Landing struct {
+	OpportunityID   string           `json:"opportunityId"`
+	Signature       string           `json:"signature"`
+	Status          string           `json:"status"` // "landed", "failed", "dropped" or "unreconciled"
+	Slot            uint64           `json:"slot,omitempty"`
+	Error           string           `json:"error,omitempty"`
+	FeeLamports     uint64           `json:"feeLamports"` // SOL the wallet spent, fees and tip included
+	TokenDeltas     map[string]int64 `json:"tokenDeltas,omitempty"`
+	PredictedProfit float64          `json:"predictedProfit"`
+	RealizedProfit  float64          `json:"realizedProfit"`
+	Gap             float64          `json:"gap"` // Predicted minus realized
+}
+
+// profitGap accumulates predicted and realized profit of a group of landings
+type profitGap struct {
+	landings  int
+	predicted float64
+	realized  float64
+}
+
+// LandingTracker follows sent transactions until they land and reconciles
+// their realized profit with the prediction
+type LandingTracker struct {
+	client  *rpc.Client
+	ws      *ws.Client // Optional, statuses are polled without it
+	wallet  solana.PublicKey
+	journal *OpportunityJournal
+
+	mu       sync.Mutex
+	statuses map[string]int
+	byDex    map[string]*profitGap
+	byCycle  map[string]*profitGap
+}
+
+// NewLandingTracker creates a tracker for transactions of wallet
+func NewLandingTracker(client *rpc.Client, wsClient *ws.Client, wallet solana.PublicKey, journal *OpportunityJournal) *LandingTracker {
+	return &LandingTracker{
+		client:   client,
+		ws:       wsClient,
+		wallet:   wallet,
+		journal:  journal,
+		statuses: make(map[string]int),
+		byDex:    make(map[string]*profitGap),
+		byCycle:  make(map[string]*profitGap),
+	}
+}
+
+// Track waits for the transaction to be confirmed, reads its balance changes
+// and records the landing. It blocks until then, so callers run it in a
+// goroutine.
+func (t *LandingTracker) Track(ctx context.Context, signature solana.Signature, opp *Opportunity) *Landing {
+	landing := &Landing{
+		OpportunityID:   opp.ID,
+		Signature:       signature.String(),
+		PredictedProfit: opp.NetProfitQuote,
+	}
+
+	if err := t.waitConfirmed(ctx, signature); err != nil {
+		landing.Status = "dropped"
+		landing.Error = err.Error()
+		t.record(landing, opp)
+		return landing
+	}
+
+	err := t.reconcile(ctx, signature, opp, landing)
+	backoff := reconcileBackoff
+	for attempt := 1; err != nil && attempt < reconcileAttempts && ctx.Err() == nil; attempt++ {
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+			err = t.reconcile(ctx, signature, opp, landing)
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		// Confirmed but unreadable: the outcome and profit are unknown
+		log.Printf("Failed to reconcile %s: %v", signature, err)
+		landing.Status = "unreconciled"
+		landing.Error = err.Error()
+	}
+
+	t.record(landing, opp)
+	return landing
+}
+
+// waitConfirmed waits for the signature to reach confirmed commitment, by
+// subscription if there is a WebSocket client, by polling otherwise
+func (t *LandingTracker) waitConfirmed(ctx context.Context, signature solana.Signature) error {
+	if t.ws == nil {
+		return confirmSignature(ctx, t.client, signature, landingTimeout)
+	}
+
+	sub, err := t.ws.SignatureSubscribe(signature, rpc.CommitmentConfirmed)
+	if err != nil {
+		log.Printf("Failed to subscribe to %s, polling instead: %v", signature, err)
+		return confirmSignature(ctx, t.client, signature, landingTimeout)
+	}
+	defer sub.Unsubscribe()
+
+	ctx, cancel := context.WithTimeout(ctx, landingTimeout)
+	defer cancel()
+	if _, err := sub.Recv(ctx); err != nil {
+		return fmt.Errorf("transaction %s not confirmed within %v: %v", signature, landingTimeout, err)
+	}
+	// Errors are read from the transaction itself
+	return nil
+}
+
+// reconcile reads the wallet's balance changes from the confirmed transaction
+func (t *LandingTracker) reconcile(ctx context.Context, signature solana.Signature, opp *Opportunity, landing *Landing) error {
+	version := rpc.MaxSupportedTransactionVersion0
+	tx, err := t.client.GetTransaction(ctx, signature, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &version,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch transaction: %v", err)
+	}
+	if tx.Meta == nil {
+		return fmt.Errorf("transaction has no metadata")
+	}
+
+	landing.Slot = tx.Slot
+	landing.Status = "landed"
+	if tx.Meta.Err != nil {
+		landing.Status = "failed"
+		landing.Error = fmt.Sprintf("%v", tx.Meta.Err)
+	}
+
+	// The wallet pays for the transaction, so it is the first account
+	if len(tx.Meta.PreBalances) > 0 && len(tx.Meta.PostBalances) > 0 && tx.Meta.PreBalances[0] > tx.Meta.PostBalances[0] {
+		landing.FeeLamports = tx.Meta.PreBalances[0] - tx.Meta.PostBalances[0]
+	}
+
+	deltas, err := t.tokenDeltas(tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances)
+	if err != nil {
+		return err
+	}
+	landing.TokenDeltas = deltas
+
+	// Value the start token and SOL at the rates the opportunity was priced
+	// at, and the other tokens through the start token
+	var realized float64
+	if opp.InputAmount > 0 {
+		for _, value := range opp.mintValues() {
+			realized += float64(deltas[value.mint]) * value.value * opp.InputQuote / opp.InputAmount
+		}
+	}
+	if opp.CostLamports > 0 {
+		realized -= float64(landing.FeeLamports) * opp.CostQuote / float64(opp.CostLamports)
+	}
+	landing.RealizedProfit = realized
+	landing.Gap = landing.PredictedProfit - landing.RealizedProfit
+	return nil
+}
+
+// tokenDeltas returns the raw balance change of every token account the
+// wallet owns in the transaction, by mint
+func (t *LandingTracker) tokenDeltas(pre, post []rpc.TokenBalance) (map[string]int64, error) {
+	deltas := make(map[string]int64)
+	add := func(balances []rpc.TokenBalance, sign int64) error {
+		for _, balance := range balances {
+			if balance.Owner == nil || !balance.Owner.Equals(t.wallet) || balance.UiTokenAmount == nil {
+				continue
+			}
+			amount, err := strconv.ParseInt(balance.UiTokenAmount.Amount, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid token amount %q: %v", balance.UiTokenAmount.Amount, err)
+			}
+			deltas[balance.Mint.String()] += sign * amount
+		}
+		return nil
+	}
+
+	// Accounts created by the transaction have no pre balance
+	if err := add(pre, -1); err != nil {
+		return nil, err
+	}
+	if err := add(post, 1); err != nil {
+		return nil, err
+	}
+	return deltas, nil
+}
+
+// record counts the landing, adds its profit gap to every DEX and the cycle
+// it traded through and journals it
+func (t *LandingTracker) record(landing *Landing, opp *Opportunity) {
+	t.mu.Lock()
+	t.statuses[landing.Status]++
+	if landing.Status == "landed" || landing.Status == "failed" {
+		groups := []*profitGap{gapFor(t.byCycle, opp.Cycle().Canonical().String())}
+		seen := make(map[string]bool)
+		for _, hop := range opp.Hops {
+			if !seen[hop.Dex] {
+				seen[hop.Dex] = true
+				groups = append(groups, gapFor(t.byDex, hop.Dex))
+			}
+		}
+		for _, group := range groups {
+			group.landings++
+			group.predicted += landing.PredictedProfit
+			group.realized += landing.RealizedProfit
+		}
+	}
+	t.mu.Unlock()
+
+	log.Printf("Opportunity %s %s (%s): realized %.6f, predicted %.6f",
+		landing.OpportunityID, landing.Status, landing.Signature, landing.RealizedProfit, landing.PredictedProfit)
+	if t.journal != nil {
+		if err := t.journal.RecordLanding(landing); err != nil {
+			log.Printf("Failed to journal landing: %v", err)
+		}
+	}
+}
+
+// gapFor returns the accumulator of a group, creating it if needed
+func gapFor(groups map[string]*profitGap, key string) *profitGap {
+	gap, ok := groups[key]
+	if !ok {
+		gap = &profitGap{}
+		groups[key] = gap
+	}
+	return gap
+}
+
+// String summarizes landing rates and the profit gap per DEX and per cycle
+func (t *LandingTracker) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Landed %d, failed %d, dropped %d, unreconciled %d",
+		t.statuses["landed"], t.statuses["failed"], t.statuses["dropped"], t.statuses["unreconciled"])
+	for _, groups := range []struct {
+		name string
+		gaps map[string]*profitGap
+	}{{"DEX", t.byDex}, {"cycle", t.byCycle}} {
+		keys := make([]string, 0, len(groups.gaps))
+		for key := range groups.gaps {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			gap := groups.gaps[key]
+			fmt.Fprintf(&b, "\n  %s %s: %d landings, predicted %.6f, realized %.6f, gap %.6f",
+				groups.name, key, gap.landings, gap.predicted, gap.realized, gap.predicted-gap.realized)
+		}
+	}
+	return b.String()
+}
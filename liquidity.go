@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+)
+
+// LiquidityFilter drops edges of pools with too little liquidity and prices
+// the rest at a reference trade size instead of the marginal spot rate, so
+// dust pools cannot create phantom cycles
+type LiquidityFilter struct {
+	quoteToken    string
+	quoteScale    float64 // Raw units per whole quote token
+	minTVL        float64 // Whole quote tokens
+	referenceSize float64
+}
+
+// NewLiquidityFilter creates the filter configured by cfg, or nil if both
+// the minimum TVL and the reference size are disabled
+func NewLiquidityFilter(cfg *Config) *LiquidityFilter {
+	if cfg.MinPoolTVL <= 0 && cfg.ReferenceTradeSize <= 0 {
+		return nil
+	}
+	return &LiquidityFilter{
+		quoteToken:    cfg.QuoteToken,
+		quoteScale:    math.Pow10(cfg.Tokens[cfg.QuoteToken].Decimals),
+		minTVL:        cfg.MinPoolTVL,
+		referenceSize: cfg.ReferenceTradeSize,
+	}
+}
+
+// Apply returns a snapshot holding the edges that pass the filter, with their
+// TVL set and their rates taken at the reference size, and the pools dropped.
+// Pools whose tokens cannot be valued in the quote token are dropped too.
+func (f *LiquidityFilter) Apply(snap *GraphSnapshot) (*GraphSnapshot, []string) {
+	if f == nil {
+		return snap, nil
+	}
+
+	// Raw quote units per raw unit of each token, at mid prices
+	prices := make(map[string]float64, len(snap.Vertices))
+	for _, vertex := range snap.Vertices {
+		if price, ok := snap.ConvertRaw(1, vertex, f.quoteToken); ok {
+			prices[vertex] = price
+		}
+	}
+
+	var dropped []string
+	seen := make(map[string]bool)
+	drop := func(pool string) {
+		if !seen[pool] {
+			seen[pool] = true
+			dropped = append(dropped, pool)
+		}
+	}
+
+	edges := make([]Edge, 0, len(snap.Edges))
+	for _, edge := range snap.Edges {
+		priceIn, okIn := prices[edge.From]
+		priceOut, okOut := prices[edge.To]
+		if !okIn || !okOut {
+			drop(edge.Pool)
+			continue
+		}
+
+		edge.TVLQuote = (edge.ReserveIn*priceIn + edge.ReserveOut*priceOut) / f.quoteScale
+		if edge.TVLQuote < f.minTVL {
+			drop(edge.Pool)
+			continue
+		}
+
+		if f.referenceSize > 0 {
+			size := f.referenceSize * f.quoteScale / priceIn
+			rate := hopFromEdge(edge).Out(size) / size
+			if rate <= 0 || math.IsNaN(rate) {
+				drop(edge.Pool)
+				continue
+			}
+			edge.Rate = rate
+			edge.Weight = -math.Log(rate)
+		}
+		edges = append(edges, edge)
+	}
+
+	return newGraphSnapshot(snap.Vertices, edges, snap.Version), dropped
+}
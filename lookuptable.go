@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var addressLookupTableProgramID = solana.MustPublicKeyFromBase58("AddressLookupTab1e1111111111111111111111111")
+
+// Address lookup table program instruction tags
+const (
+	lookupTableCreate uint32 = 0
+	lookupTableExtend uint32 = 2
+)
+
+// Addresses added per extend instruction, small enough to fit a transaction
+const lookupTableExtendBatch = 20
+
+// LookupTableManager keeps the address lookup tables used to compile
+// versioned transactions, and creates and extends the owner's own table
+type LookupTableManager struct {
+	client *rpc.Client
+	owner  solana.PublicKey
+
+	mu     sync.RWMutex
+	tables map[solana.PublicKey]solana.PublicKeySlice
+	owned  solana.PublicKey // Table the owner is the authority of, zero if none yet
+}
+
+// NewLookupTableManager creates a manager for tables of owner
+func NewLookupTableManager(client *rpc.Client, owner solana.PublicKey) *LookupTableManager {
+	return &LookupTableManager{
+		client: client,
+		owner:  owner,
+		tables: make(map[solana.PublicKey]solana.PublicKeySlice),
+	}
+}
+
+// Load fetches and caches the given tables. The first active table the owner
+// is the authority of becomes the one Sync extends.
+func (m *LookupTableManager) Load(ctx context.Context, addresses []string) error {
+	for _, address := range addresses {
+		table, err := solana.PublicKeyFromBase58(address)
+		if err != nil {
+			return fmt.Errorf("invalid lookup table %s: %v", address, err)
+		}
+		if err := m.refresh(ctx, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ensure loads the given tables that are not cached yet, such as those a
+// Jupiter route needs
+func (m *LookupTableManager) Ensure(ctx context.Context, addresses []string) error {
+	missing := make([]string, 0, len(addresses))
+	m.mu.RLock()
+	for _, address := range addresses {
+		table, err := solana.PublicKeyFromBase58(address)
+		if err != nil {
+			m.mu.RUnlock()
+			return fmt.Errorf("invalid lookup table %s: %v", address, err)
+		}
+		if _, ok := m.tables[table]; !ok {
+			missing = append(missing, address)
+		}
+	}
+	m.mu.RUnlock()
+	return m.Load(ctx, missing)
+}
+
+// refresh fetches one table and replaces its cached addresses
+func (m *LookupTableManager) refresh(ctx context.Context, table solana.PublicKey) error {
+	info, err := m.client.GetAccountInfoWithOpts(ctx, table, &rpc.GetAccountInfoOpts{
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch lookup table %s: %v", table, err)
+	}
+	state, err := addresslookuptable.DecodeAddressLookupTableState(info.Value.Data.GetBinary())
+	if err != nil {
+		return fmt.Errorf("failed to decode lookup table %s: %v", table, err)
+	}
+	if !state.IsActive() {
+		return fmt.Errorf("lookup table %s is deactivated", table)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tables[table] = state.Addresses
+	if m.owned.IsZero() && state.Authority != nil && state.Authority.Equals(m.owner) {
+		m.owned = table
+	}
+	return nil
+}
+
+// Tables returns the cached tables in the form transactions are compiled with
+func (m *LookupTableManager) Tables() map[solana.PublicKey]solana.PublicKeySlice {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tables := make(map[solana.PublicKey]solana.PublicKeySlice, len(m.tables))
+	for table, addresses := range m.tables {
+		tables[table] = addresses
+	}
+	return tables
+}
+
+// Missing returns the accounts that are in none of the cached tables
+func (m *LookupTableManager) Missing(accounts []solana.PublicKey) []solana.PublicKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	known := make(map[solana.PublicKey]bool)
+	for _, addresses := range m.tables {
+		for _, address := range addresses {
+			known[address] = true
+		}
+	}
+
+	var missing []solana.PublicKey
+	for _, account := range accounts {
+		if !known[account] {
+			known[account] = true
+			missing = append(missing, account)
+		}
+	}
+	return missing
+}
+
+// Sync makes sure every account is in a table, creating the owner's table if
+// it has none and extending it with the missing accounts. Each batch of
+// instructions is passed to send, which must land it before returning.
+func (m *LookupTableManager) Sync(ctx context.Context, accounts []solana.PublicKey, send func(context.Context, []solana.Instruction) error) error {
+	missing := m.Missing(accounts)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	m.mu.RLock()
+	table := m.owned
+	m.mu.RUnlock()
+
+	if table.IsZero() {
+		slot, err := m.client.GetSlot(ctx, rpc.CommitmentFinalized)
+		if err != nil {
+			return fmt.Errorf("failed to fetch slot for lookup table: %v", err)
+		}
+		ix, address, err := createLookupTableInstruction(m.owner, slot)
+		if err != nil {
+			return err
+		}
+		if err := send(ctx, []solana.Instruction{ix}); err != nil {
+			return fmt.Errorf("failed to create lookup table: %v", err)
+		}
+		log.Printf("Created lookup table %s, add it to execution.lookupTables", address)
+
+		m.mu.Lock()
+		m.owned = address
+		m.tables[address] = solana.PublicKeySlice{}
+		m.mu.Unlock()
+		table = address
+	}
+
+	for start := 0; start < len(missing); start += lookupTableExtendBatch {
+		end := min(start+lookupTableExtendBatch, len(missing))
+		ix := extendLookupTableInstruction(table, m.owner, missing[start:end])
+		if err := send(ctx, []solana.Instruction{ix}); err != nil {
+			return fmt.Errorf("failed to extend lookup table %s: %v", table, err)
+		}
+	}
+	log.Printf("Extended lookup table %s with %d accounts", table, len(missing))
+
+	// New addresses are usable from the next slot on
+	return m.refresh(ctx, table)
+}
+
+// createLookupTableInstruction creates a table owned and paid for by
+// authority. Its address derives from the authority and a recent slot.
+func createLookupTableInstruction(authority solana.PublicKey, recentSlot uint64) (solana.Instruction, solana.PublicKey, error) {
+	slot := make([]byte, 8)
+	binary.LittleEndian.PutUint64(slot, recentSlot)
+	table, bump, err := solana.FindProgramAddress([][]byte{authority.Bytes(), slot}, addressLookupTableProgramID)
+	if err != nil {
+		return nil, solana.PublicKey{}, fmt.Errorf("failed to derive lookup table address: %v", err)
+	}
+
+	data := make([]byte, 13)
+	binary.LittleEndian.PutUint32(data[0:4], lookupTableCreate)
+	copy(data[4:12], slot)
+	data[12] = bump
+
+	accounts := solana.AccountMetaSlice{
+		solana.Meta(table).WRITE(),
+		solana.Meta(authority).SIGNER(),
+		solana.Meta(authority).WRITE().SIGNER(), // Payer
+		solana.Meta(solana.SystemProgramID),
+	}
+	return solana.NewInstruction(addressLookupTableProgramID, accounts, data), table, nil
+}
+
+// extendLookupTableInstruction appends addresses to a table, the authority
+// paying for the extra space
+func extendLookupTableInstruction(table, authority solana.PublicKey, addresses []solana.PublicKey) solana.Instruction {
+	data := make([]byte, 12, 12+32*len(addresses))
+	binary.LittleEndian.PutUint32(data[0:4], lookupTableExtend)
+	binary.LittleEndian.PutUint64(data[4:12], uint64(len(addresses)))
+	for _, address := range addresses {
+		data = append(data, address.Bytes()...)
+	}
+
+	accounts := solana.AccountMetaSlice{
+		solana.Meta(table).WRITE(),
+		solana.Meta(authority).SIGNER(),
+		solana.Meta(authority).WRITE().SIGNER(), // Payer
+		solana.Meta(solana.SystemProgramID),
+	}
+	return solana.NewInstruction(addressLookupTableProgramID, accounts, data)
+}
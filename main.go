@@ -3,11 +3,16 @@ package main
 import (
 	"context"
 	"encoding/binary"
+	"flag"
 	"fmt"
 	"log"
 	"math"
-	"math/big"
-	"sync"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
@@ -24,21 +29,6 @@ type Pool struct {
 	PoolPubKey solana.PublicKey
 }
 
-// Graph represents the exchange rate graph for arbitrage detection
-type Graph struct {
-	Vertices []string
-	Edges    []Edge
-	mu       sync.RWMutex
-}
-
-// Edge represents a directed edge in the exchange rate graph
-type Edge struct {
-	From   string
-	To     string
-	Weight float64 // Negative log of exchange rate
-	Rate   float64
-}
-
 // RaydiumPoolState represents the state of a Raydium liquidity pool
 type RaydiumPoolState struct {
 	Status            uint64
@@ -63,108 +53,300 @@ func isSignificantlyDifferent(a, b float64) bool {
 }
 
 func main() {
+	configPath := flag.String("config", "", "Path to a JSON config file")
+	resetRisk := flag.Bool("reset-risk", false, "Resume execution halted by a risk limit in a previous run")
+	flag.Parse()
 
-	ctx := context.Background()
-
-	// Initialize Solana WebSocket client
-	wsClient, err := ws.Connect(ctx, rpc.MainNetBeta_WS)
+	cfg, err := loadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to connect to Solana WebSocket: %v", err)
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Check the tokens before any of their pools enter the graph
+	client := rpc.New(cfg.RPCEndpoint)
+	if err := applyMintPolicy(ctx, client, cfg); err != nil {
+		log.Fatalf("Failed to inspect token mints: %v", err)
+	}
+	if len(cfg.Pools) == 0 {
+		log.Fatalf("Every configured pool is excluded by the mint policy")
+	}
+
+	// Initialize a Solana WebSocket client per provider
+	clients := make(map[string]*ws.Client)
+	names := providerNames(cfg.WSEndpoints)
+	for i, endpoint := range cfg.WSEndpoints {
+		wsClient, err := ws.Connect(ctx, endpoint)
+		if err != nil {
+			log.Printf("Failed to connect to Solana WebSocket %s: %v", endpoint, err)
+			continue
+		}
+		defer wsClient.Close()
+		clients[names[i]] = wsClient
+	}
+	if len(clients) == 0 {
+		log.Fatalf("Failed to connect to any Solana WebSocket endpoint")
 	}
-	defer wsClient.Close()
 
 	// Initialize exchange rate graph
-	graph := &Graph{
-		Vertices: make([]string, 0),
-		Edges:    make([]Edge, 0),
+	graph := NewGraph()
+
+	transferFees := NewTransferFeeSchedule(client, cfg.Tokens)
+	if err := transferFees.Refresh(ctx); err != nil {
+		log.Printf("Failed to fetch transfer fee epoch: %v", err)
 	}
+	go transferFees.Run(ctx)
+	graph.SetTransferFees(transferFees)
 
 	// Subscribe to account updates
-	go monitorAccounts(ctx, wsClient, graph)
+	filter, err := NewTradeFilter(cfg)
+	if err != nil {
+		log.Fatalf("Invalid trade lists: %v", err)
+	}
+	lists := NewTradeLists(filter)
+	if *configPath != "" {
+		go reloadTradeLists(ctx, *configPath, lists)
+	}
+	go monitorAccounts(ctx, clients, cfg.Pools, graph, lists)
+
+	var journal *OpportunityJournal
+	if cfg.JournalPath != "" {
+		journal, err = OpenOpportunityJournal(cfg.JournalPath)
+		if err != nil {
+			log.Fatalf("Failed to open opportunity journal: %v", err)
+		}
+		defer journal.Close()
+	}
+
+	var executor *Executor
+	if cfg.Execution.Enabled {
+		executor, err = NewExecutor(ctx, cfg, journal)
+		if err != nil {
+			log.Fatalf("Failed to set up execution: %v", err)
+		}
+		go reportPeriodically(executor.Landings, 5*time.Minute, ctx.Done())
+		if *resetRisk {
+			executor.Risk().Reset("-reset-risk")
+		}
+		if cfg.Rebalance.Enabled {
+			go NewRebalancer(cfg, graph, executor).Run(ctx)
+		}
+	}
+
+	if cfg.ControlAddress != "" {
+		control, err := NewControlServer(cfg.ControlAddress, cfg.ControlToken)
+		if err != nil {
+			log.Fatalf("Failed to start control API: %v", err)
+		}
+		if executor != nil {
+			control.HandleRisk(executor.Risk())
+		}
+		go control.Run(ctx)
+	}
+
+	var paper *PaperTrader
+	if cfg.Paper.Enabled {
+		paper = NewPaperTrader(cfg)
+		if cfg.Paper.SummarySeconds > 0 {
+			go reportPeriodically(paper.Summary, time.Duration(cfg.Paper.SummarySeconds)*time.Second, ctx.Done())
+		}
+	}
 
-	// Start arbitrage detection loop
-	detectArbitrage(graph)
+	// Start arbitrage detection loop, until interrupted
+	detectArbitrage(ctx, graph, cfg, lists, journal, executor, paper)
+
+	if paper != nil {
+		log.Print(paper.Summary())
+	}
+	if executor != nil {
+		log.Print(executor.Landings())
+	}
 }
 
-// monitorAccounts subscribes to relevant pool account updates
-func monitorAccounts(ctx context.Context, client *ws.Client, graph *Graph) {
-	// Raydium pool accounts
-	pools := map[string]struct {
-		name       string
-		baseToken  string
-		quoteToken string
-	}{
-		"8sLbNZoA1cfnvMJLPfp98ZLAnFSYCFApfJKMbiXNLwxj": {
-			name:       "USDC-SOL",
-			baseToken:  "USDC",
-			quoteToken: "SOL",
-		},
-		"2AXXcN6oN9bBT5owwmTH53C7QHUXvhLeu718Kqt8rvY2": {
-			name:       "SOL-GRASS",
-			baseToken:  "SOL",
-			quoteToken: "GRASS",
-		},
-	}
-
-	for poolPubKey, poolInfo := range pools {
-		// Create a closure to capture pool info
-		go func(pubKey string, info struct {
-			name       string
-			baseToken  string
-			quoteToken string
-		}) {
-			poolAccount, err := solana.PublicKeyFromBase58(pubKey)
+// reloadTradeLists rereads the trade lists and pool flags from the config
+// file on SIGHUP. Pools added to the file need a restart.
+func reloadTradeLists(ctx context.Context, path string, lists *TradeLists) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			cfg, err := loadConfig(path)
 			if err != nil {
-				log.Printf("Failed to parse pool public key %s: %v", pubKey, err)
-				return
+				log.Printf("Keeping the current trade lists: %v", err)
+				continue
 			}
-
-			// Subscribe to account updates
-			sub, err := client.AccountSubscribe(
-				poolAccount,
-				rpc.CommitmentConfirmed,
-			)
+			filter, err := NewTradeFilter(cfg)
 			if err != nil {
-				log.Printf("Failed to subscribe to account %s: %v", pubKey, err)
-				return
+				log.Printf("Keeping the current trade lists: %v", err)
+				continue
 			}
-			defer sub.Unsubscribe()
+			lists.Update(filter)
+			log.Printf("Reloaded trade lists from %s", path)
+		}
+	}
+}
 
-			log.Printf("Successfully subscribed to Raydium pool %s (%s)", info.name, pubKey)
+// providerNames derives a short, unique provider label from each WebSocket
+// endpoint: its host, numbered when several endpoints share one, so API keys
+// in the path or query stay out of the logs
+func providerNames(endpoints []string) []string {
+	hosts := make([]string, len(endpoints))
+	count := make(map[string]int)
+	for i, endpoint := range endpoints {
+		hosts[i] = endpoint
+		if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+			hosts[i] = u.Host
+		}
+		count[hosts[i]]++
+	}
 
-			// Start receiving updates
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case update := <-sub.Response():
-					if update.Value.Data == nil {
-						continue
-					}
+	names := make([]string, len(endpoints))
+	seen := make(map[string]int)
+	for i, host := range hosts {
+		names[i] = host
+		if count[host] > 1 {
+			seen[host]++
+			names[i] = fmt.Sprintf("%s#%d", host, seen[host])
+		}
+	}
+	return names
+}
 
-					// Parse pool state
-					poolState, err := parseRaydiumPoolState(update.Value.Data.GetBinary())
-					if err != nil {
-						log.Printf("Failed to parse pool state for %s: %v", info.name, err)
-						continue
-					}
+// monitorAccounts subscribes to relevant pool account updates on every
+// provider and applies the first arrival of each change to the graph
+func monitorAccounts(ctx context.Context, clients map[string]*ws.Client, pools []PoolConfig, graph *Graph, lists *TradeLists) {
+	updates := make(chan AccountUpdate, 256)
+	dedup := NewUpdateDeduplicator()
 
-					// Update graph with new exchange rates
-					updateGraphWithPoolState(graph, poolState, info.baseToken, info.quoteToken)
+	poolsByAddress := make(map[string]PoolConfig)
+	for _, pool := range pools {
+		poolsByAddress[pool.Address] = pool
+	}
 
-					log.Printf("Pool Update (%s) - Base Reserve (%s): %d, Quote Reserve (%s): %d",
-						info.name,
-						info.baseToken,
-						poolState.BaseReserve,
-						info.quoteToken,
-						poolState.QuoteReserve)
+	// Subscriptions of the pools the trade lists allow, on every provider
+	subscriptions := make(map[string]context.CancelFunc)
+	syncSubscriptions := func() {
+		filter := lists.Filter()
+		for _, pool := range pools {
+			cancel, subscribed := subscriptions[pool.Address]
+			allowed := filter.PoolAllowed(pool.Address)
+			switch {
+			case allowed && !subscribed:
+				poolCtx, cancel := context.WithCancel(ctx)
+				subscriptions[pool.Address] = cancel
+				for provider, client := range clients {
+					go subscribePool(poolCtx, provider, client, pool.Address, pool, updates)
 				}
+			case !allowed && subscribed:
+				cancel()
+				delete(subscriptions, pool.Address)
+				graph.RemovePool(pool.Address)
+				log.Printf("Unsubscribed from pool %s, no longer allowed by the trade lists", pool.Name)
+			case !allowed:
+				log.Printf("Skipping pool %s, not allowed by the trade lists", pool.Name)
+			}
+		}
+	}
+	syncSubscriptions()
+
+	statsTicker := time.NewTicker(30 * time.Second)
+	defer statsTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-statsTicker.C:
+			log.Printf("Provider win rates: %s", dedup)
+		case <-lists.Changed():
+			syncSubscriptions()
+		case update := <-updates:
+			// Updates may still arrive from a subscription being cancelled
+			if _, ok := subscriptions[update.Account.String()]; !ok {
+				continue
+			}
+			if !dedup.Accept(update) {
+				continue
+			}
+
+			info := poolsByAddress[update.Account.String()]
+
+			// Parse pool state
+			poolState, err := parseRaydiumPoolState(update.Data)
+			if err != nil {
+				log.Printf("Failed to parse pool state for %s: %v", info.Name, err)
+				continue
 			}
-		}(poolPubKey, poolInfo)
+
+			// Update graph with new exchange rates
+			updateGraphWithPoolState(graph, poolState, info, update)
+
+			log.Printf("Pool Update (%s) via %s at slot %d - Base Reserve (%s): %d, Quote Reserve (%s): %d",
+				info.Name,
+				update.Provider,
+				update.Slot,
+				info.BaseToken,
+				poolState.BaseReserve,
+				info.QuoteToken,
+				poolState.QuoteReserve)
+		}
+	}
+}
+
+// subscribePool forwards account updates of one pool from one provider
+func subscribePool(ctx context.Context, provider string, client *ws.Client, pubKey string, info PoolConfig, updates chan<- AccountUpdate) {
+	poolAccount, err := solana.PublicKeyFromBase58(pubKey)
+	if err != nil {
+		log.Printf("Failed to parse pool public key %s: %v", pubKey, err)
+		return
 	}
 
-	// Keep the main goroutine running
-	<-ctx.Done()
+	// Subscribe to account updates
+	sub, err := client.AccountSubscribe(
+		poolAccount,
+		rpc.CommitmentConfirmed,
+	)
+	if err != nil {
+		log.Printf("Failed to subscribe to account %s on %s: %v", pubKey, provider, err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	log.Printf("Successfully subscribed to Raydium pool %s (%s) on %s", info.Name, pubKey, provider)
+
+	// Start receiving updates
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-sub.Err():
+			log.Printf("Subscription to %s on %s ended: %v", info.Name, provider, err)
+			return
+		case result := <-sub.Response():
+			if result == nil || result.Value.Data == nil {
+				continue
+			}
+
+			select {
+			case updates <- AccountUpdate{
+				Provider: provider,
+				Account:  poolAccount,
+				Slot:     result.Context.Slot,
+				Data:     result.Value.Data.GetBinary(),
+				Received: time.Now(),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
 }
 
 func parseRaydiumPoolState(data []byte) (*RaydiumPoolState, error) {
@@ -189,74 +371,13 @@ func parseRaydiumPoolState(data []byte) (*RaydiumPoolState, error) {
 	return state, nil
 }
 
-func updateGraphWithPoolState(graph *Graph, state *RaydiumPoolState, baseToken, quoteToken string) {
-	graph.mu.Lock()
-	defer graph.mu.Unlock()
-
-	// Use big.Float for precise calculations
-	baseReserve := new(big.Float).SetUint64(state.BaseReserve)
-	quoteReserve := new(big.Float).SetUint64(state.QuoteReserve)
-
-	// Calculate rates with high precision
-	baseToQuotePrice, _ := new(big.Float).Quo(quoteReserve, baseReserve).Float64()
-	quoteToBasePrice, _ := new(big.Float).Quo(baseReserve, quoteReserve).Float64()
-
-	// Apply fee with precision
-	fee := 0.003
-	baseToQuotePrice *= (1 - fee)
-	quoteToBasePrice *= (1 - fee)
-
-	// Convert to negative log with precision check
-	baseToQuoteRate := -math.Log(baseToQuotePrice)
-	quoteToBaseRate := -math.Log(quoteToBasePrice)
-
-	// Check for invalid rates
-	if math.IsInf(baseToQuoteRate, 0) || math.IsNaN(baseToQuoteRate) ||
-		math.IsInf(quoteToBaseRate, 0) || math.IsNaN(quoteToBaseRate) {
-		log.Printf("Warning: Invalid rate calculated for %s-%s pool", baseToken, quoteToken)
-		return
-	}
-
-	log.Printf("Pool %s-%s: 1 %s = %.12f %s, 1 %s = %.12f %s",
-		baseToken, quoteToken,
-		baseToken, baseToQuotePrice, quoteToken,
-		quoteToken, quoteToBasePrice, baseToken)
-
-	// Update vertices if needed
-	found := false
-	for _, v := range graph.Vertices {
-		if v == baseToken || v == quoteToken {
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		graph.Vertices = append(graph.Vertices, baseToken, quoteToken)
-		log.Printf("Added new vertices: %s, %s", baseToken, quoteToken)
-	}
-
-	// Update edges with precision handling
-	graph.addEdge(baseToken, quoteToken, baseToQuotePrice)
-	graph.addEdge(quoteToken, baseToken, quoteToBasePrice)
-}
-
-func (g *Graph) addEdge(from, to string, rate float64) {
-	// For arbitrage detection:
-	// If rate1 * rate2 * rate3 > 1 (profitable)
-	// Then ln(rate1) + ln(rate2) + ln(rate3) > 0
-	// And -ln(rate1) - ln(rate2) - ln(rate3) < 0 (negative cycle)
-	weight := -math.Log(rate)
-	g.Edges = append(g.Edges, Edge{
-		From:   from,
-		To:     to,
-		Weight: weight,
-		Rate:   rate,
-	})
+func bellmanFord(graph *GraphSnapshot) []Cycle {
+	return bellmanFordFrom(graph, graph.Vertices)
 }
 
-func bellmanFord(graph *Graph) [][]string {
-	opportunities := make([][]string, 0)
+// bellmanFordFrom runs the negative cycle search from the given start vertices only
+func bellmanFordFrom(graph *GraphSnapshot, starts []string) []Cycle {
+	opportunities := make([]Cycle, 0)
 	n := len(graph.Vertices)
 
 	if n == 0 {
@@ -264,9 +385,11 @@ func bellmanFord(graph *Graph) [][]string {
 	}
 
 	// Try starting from each vertex
-	for _, start := range graph.Vertices {
+	for _, start := range starts {
 		dist := make(map[string]float64)
-		prev := make(map[string]string)
+		// Index of the edge each vertex was last relaxed through, so cycles
+		// keep the exact pool rather than just the token pair
+		prevEdge := make(map[string]int)
 
 		// Initialize all distances to infinity except start
 		for _, v := range graph.Vertices {
@@ -276,81 +399,43 @@ func bellmanFord(graph *Graph) [][]string {
 
 		// Relax edges |V| - 1 times
 		for i := 0; i < n-1; i++ {
-			for _, edge := range graph.Edges {
+			for j, edge := range graph.Edges {
 				if dist[edge.From] != math.Inf(1) {
 					newDist := dist[edge.From] + edge.Weight
 					if newDist < dist[edge.To] {
 						dist[edge.To] = newDist
-						prev[edge.To] = edge.From
+						prevEdge[edge.To] = j
 					}
 				}
 			}
 		}
 
 		// Check for negative cycles (which indicate arbitrage opportunities)
-		visited := make(map[string]bool)
-		for _, edge := range graph.Edges {
+		reported := make(map[string]bool)
+		for j, edge := range graph.Edges {
 			if dist[edge.From] != math.Inf(1) {
 				newDist := dist[edge.From] + edge.Weight
 				if newDist < dist[edge.To] {
 					// Found a negative cycle (arbitrage opportunity)
-					current := edge.From
-					cycle := []string{current}
-					visited[current] = true
-
-					for {
-						next := prev[current]
-						if next == "" {
-							break
-						}
-						if visited[next] {
-							// Complete the cycle
-							cycleStart := -1
-							for i, v := range cycle {
-								if v == next {
-									cycleStart = i
-									break
-								}
-							}
-							if cycleStart != -1 {
-								actualCycle := append(cycle[cycleStart:], next)
-
-								// Calculate actual cycle profit
-								amount := 1.0
-								rates := make([]float64, 0)
-
-								for i := 0; i < len(actualCycle)-1; i++ {
-									from := actualCycle[i]
-									to := actualCycle[i+1]
-
-									// Find the direct exchange rate
-									for _, e := range graph.Edges {
-										if e.From == from && e.To == to {
-											rate := math.Exp(-e.Weight) // Use exp(-weight) to get back original rate
-											rates = append(rates, rate)
-											amount *= rate
-											break
-										}
-									}
-								}
-
-								profitPercent := (amount - 1.0) * 100
-
-								log.Printf("Analyzing cycle: %v", actualCycle)
-								log.Printf("Exchange rates: %v", rates)
-								log.Printf("Final amount: %.12f (%.2f%%)", amount, profitPercent)
-
-								// Only add to opportunities if profit is above threshold
-								if amount > 1.0 { // Any profit is good for testing
-									log.Printf("Found profitable cycle! Profit: %.2f%%", profitPercent)
-									opportunities = append(opportunities, actualCycle)
-								}
-							}
-							break
-						}
-						cycle = append(cycle, next)
-						visited[next] = true
-						current = next
+					prevEdge[edge.To] = j
+					cycle, ok := traceCycle(graph, prevEdge, edge.To, n)
+					if !ok || reported[cycle.String()] {
+						continue
+					}
+					reported[cycle.String()] = true
+
+					// Calculate actual cycle profit on the pools that form it
+					amount := cycle.Amount()
+					profitPercent := cycle.ProfitPercent()
+
+					log.Printf("Analyzing cycle: %v", cycle)
+					log.Printf("Exchange rates: %v", cycle.Rates())
+					log.Printf("Final amount: %.12f (%.2f%%)", amount, profitPercent)
+
+					// Only add to opportunities if profit is above threshold
+					if amount > 1.0 { // Any profit is good for testing
+						log.Printf("Found profitable cycle! Profit: %.2f%%", profitPercent)
+						opportunities = append(opportunities, cycle)
 					}
 				}
 			}
@@ -360,52 +445,268 @@ func bellmanFord(graph *Graph) [][]string {
 	return opportunities
 }
 
-func detectArbitrage(graph *Graph) {
+// traceCycle follows the predecessor edges back from vertex until it is on a
+// cycle and returns that cycle in forward order
+func traceCycle(graph *GraphSnapshot, prevEdge map[string]int, vertex string, n int) (Cycle, bool) {
+	// Walking back n steps is guaranteed to end on the cycle
+	for i := 0; i < n; i++ {
+		j, ok := prevEdge[vertex]
+		if !ok {
+			return nil, false
+		}
+		vertex = graph.Edges[j].From
+	}
+
+	indices := make([]int, 0)
+	current := vertex
+	for {
+		j, ok := prevEdge[current]
+		if !ok || len(indices) > n {
+			return nil, false
+		}
+		indices = append(indices, j)
+		current = graph.Edges[j].From
+		if current == vertex {
+			break
+		}
+	}
+
+	// Reverse into forward order
+	for i, k := 0, len(indices)-1; i < k; i, k = i+1, k-1 {
+		indices[i], indices[k] = indices[k], indices[i]
+	}
+	return cycleFromEdges(graph, indices), true
+}
+
+// detectArbitrage runs detection whenever pool updates publish a new graph
+// snapshot. Updates arriving while a pass runs, or within the coalesce window,
+// are handled together in the next pass, which only searches cycles through
+// the edges of the pools that changed.
+func detectArbitrage(ctx context.Context, graph *Graph, cfg *Config, lists *TradeLists, journal *OpportunityJournal, executor *Executor, paper *PaperTrader) {
+	coalesce := time.Duration(cfg.CoalesceMillis) * time.Millisecond
+
+	// Changes not yet covered by a detection pass
+	pending := make(map[string]time.Time)
+
+	suppressor := NewCycleSuppressor(cfg.ReportProfitChange, time.Duration(cfg.ReportExpirySeconds)*time.Second)
+
+	liquidity := NewLiquidityFilter(cfg)
+	var lastDropped string
+
+	var queue chan *Opportunity
+	if executor != nil {
+		queue = make(chan *Opportunity, executionQueueSize)
+		go executor.Run(ctx, queue)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-graph.Updated():
+		}
+
+		if coalesce > 0 {
+			time.Sleep(coalesce)
+		}
+
+		for pool, received := range graph.TakeChanges() {
+			if first, ok := pending[pool]; !ok || received.Before(first) {
+				pending[pool] = received
+			}
+		}
 
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+		snap := graph.Snapshot()
+		if len(snap.Vertices) < 2 {
+			log.Printf("Waiting for sufficient vertices... Current count: %d", len(snap.Vertices))
+			continue
+		}
 
-	for range ticker.C {
-		graph.mu.RLock()
-		if len(graph.Vertices) < 2 {
-			log.Printf("Waiting for sufficient vertices... Current count: %d", len(graph.Vertices))
-			graph.mu.RUnlock()
+		if len(snap.Edges) < 2 {
+			log.Printf("Waiting for sufficient edges... Current count: %d", len(snap.Edges))
 			continue
 		}
 
-		if len(graph.Edges) < 2 {
-			log.Printf("Waiting for sufficient edges... Current count: %d", len(graph.Edges))
-			graph.mu.RUnlock()
+		if cfg.RequireSlotWindow && snap.SlotSpread() > cfg.SlotWindow {
+			log.Printf("Skipping snapshot %d: edges span slots %d-%d (window %d)",
+				snap.Version, snap.MinSlot, snap.MaxSlot, cfg.SlotWindow)
 			continue
 		}
 
-		// Debug print current graph state
-		log.Printf("Current Graph State - Vertices: %v", graph.Vertices)
-		for _, edge := range graph.Edges {
-			log.Printf("Edge: %s -> %s (Weight: %f)", edge.From, edge.To, edge.Weight)
+		snap, dropped := liquidity.Apply(lists.Filter().Apply(snap))
+		if names := poolNames(cfg.Pools, dropped); names != lastDropped {
+			log.Printf("Pools below %.0f %s of liquidity or without a price: [%s]", cfg.MinPoolTVL, cfg.QuoteToken, names)
+			lastDropped = names
 		}
 
-		opportunities := bellmanFord(graph)
-		graph.mu.RUnlock()
+		changed := pending
+		pending = make(map[string]time.Time)
+
+		opportunities := detectOpportunities(snap, changed, cfg, suppressor)
+		detected := time.Now()
+
+		var oldest time.Time
+		for _, received := range changed {
+			if oldest.IsZero() || received.Before(oldest) {
+				oldest = received
+			}
+		}
+		log.Printf("Detection pass on snapshot %d: %d pools changed, update-to-detection latency %v",
+			snap.Version, len(changed), detected.Sub(oldest))
 
 		if len(opportunities) > 0 {
 			log.Printf("Found %d arbitrage opportunities!", len(opportunities))
 			printArbitrageOpportunities(opportunities)
 		}
+
+		if journal != nil {
+			for _, opp := range opportunities {
+				if err := journal.Record(opp); err != nil {
+					log.Printf("Failed to journal opportunity: %v", err)
+				}
+			}
+		}
+
+		if paper != nil {
+			for _, opp := range opportunities {
+				trade := paper.Execute(opp, snap)
+				if trade.Executed {
+					log.Printf("Paper trade %s: %.0f -> %.0f %s, net %.6f %s (model %.6f)",
+						opp.ID, trade.Input, trade.Output, opp.StartToken, trade.NetProfit, cfg.QuoteToken, trade.ModelProfit)
+				} else {
+					log.Printf("Paper trade %s not executed: %s", opp.ID, trade.Reason)
+				}
+			}
+		}
+
+		// Detection carries on while a risk limit halts execution
+		if executor != nil && !executor.Risk().Halted() {
+			for _, opp := range opportunities {
+				select {
+				case queue <- opp:
+				default:
+					log.Printf("Execution queue full, dropping opportunity %s", opp.ID)
+				}
+			}
+		}
+	}
+}
+
+// detectOpportunities runs the configured cycle search on a snapshot and
+// returns the new profitable cycles through the changed pools, sized as
+// opportunities
+func detectOpportunities(snap *GraphSnapshot, changed map[string]time.Time, cfg *Config, suppressor *CycleSuppressor) []*Opportunity {
+	cycles := make([]Cycle, 0)
+	switch cfg.Detector {
+	case "bellman-ford":
+		for _, cycle := range bellmanFordFrom(snap, changedVertices(snap, changed)) {
+			if cycle.TouchesPools(changed) {
+				cycles = append(cycles, cycle.Canonical())
+			}
+		}
+	case "anchored":
+		for rank, cycle := range enumerateAnchoredCycles(snap, cfg.AnchorTokens, cfg.MaxCycleHops) {
+			if cycle.Amount() <= 1.0 || !cycle.TouchesPools(changed) {
+				continue
+			}
+			log.Printf("Ranked cycle #%d: %v, profit %.4f%%", rank+1, cycle, cycle.ProfitPercent())
+			cycles = append(cycles, cycle)
+		}
+	default:
+		for _, cycle := range incrementalCycles(snap, changed, cfg.MaxCycleHops) {
+			cycles = append(cycles, cycle.Canonical())
+		}
+	}
+
+	detected := time.Now()
+
+	// The same cycle is found once per start vertex or changed edge
+	found := len(cycles)
+	cycles = suppressor.Filter(dedupeCycles(cycles), detected)
+	if suppressed := found - len(cycles); suppressed > 0 {
+		log.Printf("Dropped %d duplicate or already reported cycles", suppressed)
+	}
+
+	opportunities := make([]*Opportunity, 0, len(cycles))
+	for _, cycle := range cycles {
+		opp, ok := newOpportunity(cycle, cfg.Tokens, detected)
+		if !ok {
+			log.Printf("Cycle %v is not profitable at any size after price impact", cycle)
+			continue
+		}
+		if !applyCostModel(opp, snap, cfg) {
+			log.Printf("Opportunity %s: cannot value profit in %s from the graph", opp.ID, cfg.QuoteToken)
+			continue
+		}
+		if opp.NetProfitQuote < cfg.MinNetProfit {
+			log.Printf("Opportunity %s: net profit %.6f %s below threshold %.6f (gross %.6f, costs %.6f)",
+				opp.ID, opp.NetProfitQuote, cfg.QuoteToken, cfg.MinNetProfit, opp.GrossProfitQuote, opp.CostQuote)
+			continue
+		}
+		opportunities = append(opportunities, opp)
+	}
+	return opportunities
+}
+
+// poolNames returns the configured names of the pools, comma separated
+func poolNames(pools []PoolConfig, addresses []string) string {
+	names := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		name := address
+		for _, pool := range pools {
+			if pool.Address == address {
+				name = pool.Name
+				break
+			}
+		}
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// changedVertices returns the endpoints of the edges of the changed pools
+func changedVertices(graph *GraphSnapshot, changed map[string]time.Time) []string {
+	vertices := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, edge := range graph.Edges {
+		if _, ok := changed[edge.Pool]; !ok {
+			continue
+		}
+		for _, v := range []string{edge.From, edge.To} {
+			if !seen[v] {
+				seen[v] = true
+				vertices = append(vertices, v)
+			}
+		}
+	}
+	return vertices
 }
 
 // Printing arbitrage opportunities
-func printArbitrageOpportunities(opportunities [][]string) {
-	for i, path := range opportunities {
-		if len(path) < 2 {
+func printArbitrageOpportunities(opportunities []*Opportunity) {
+	for i, opp := range opportunities {
+		if len(opp.Hops) < 2 {
 			continue
 		}
 
-		fmt.Printf("\n\n\n\n\nArbitrage Opportunity #%d:\n", i+1)
-		fmt.Printf("Path: %s", path[0])
-		for j := 1; j < len(path); j++ {
-			fmt.Printf(" -> %s", path[j])
+		cycle := opp.Cycle()
+		fmt.Printf("\n\n\n\n\nArbitrage Opportunity #%d (%s):\n", i+1, opp.ID)
+		fmt.Printf("Path: %s", cycle[0].From)
+		for _, hop := range cycle {
+			fmt.Printf(" -> %s", hop.To)
+		}
+		fmt.Printf("\n")
+		for j, hop := range opp.Hops {
+			fmt.Printf("  %s -> %s via pool %s (%s) at rate %.12f: %.0f in, %.0f out\n",
+				cycle[j].From, cycle[j].To, hop.Pool, hop.Direction, hop.Rate, hop.AmountIn, hop.AmountOut)
+		}
+		fmt.Printf("Spot profit: %.4f%%, slots %d-%d\n", opp.SpotProfit, opp.MinSlot, opp.MaxSlot)
+		fmt.Printf("Input: %.0f %s, gross profit: %.0f, net profit: %.0f\n", opp.InputAmount, opp.StartToken, opp.GrossProfit, opp.NetProfit)
+		fmt.Printf("Costs: %d lamports for %d compute units\n", opp.CostLamports, opp.ComputeUnits)
+		fmt.Printf("In %s: gross %.6f, costs %.6f, net %.6f", opp.QuoteToken, opp.GrossProfitQuote, opp.CostQuote, opp.NetProfitQuote)
+		if len(opp.Flags) > 0 {
+			fmt.Printf("\nToken flags: %v", opp.Flags)
 		}
 		fmt.Printf("\n\n\n\n\n")
 	}
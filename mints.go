@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Token flags a mint can carry
+const (
+	FlagFreezeAuthority   = "freezeAuthority"
+	FlagMintAuthority     = "mintAuthority"
+	FlagTransferFee       = "transferFee"
+	FlagTransferHook      = "transferHook"
+	FlagPermanentDelegate = "permanentDelegate"
+	FlagNonTransferable   = "nonTransferable"
+)
+
+// What a mint policy does with a pool trading a flagged token
+const (
+	PolicyExclude = "exclude" // Drop the pool
+	PolicyTag     = "tag"     // Keep the pool, tagging opportunities through it
+	PolicyIgnore  = "ignore"
+)
+
+// defaultMintPolicy excludes tokens whose transfers another party can block
+// or redirect, and tags those an authority could change
+func defaultMintPolicy() map[string]string {
+	return map[string]string{
+		FlagFreezeAuthority:   PolicyTag, // USDC has one
+		FlagMintAuthority:     PolicyTag,
+		FlagTransferFee:       PolicyTag,
+		FlagTransferHook:      PolicyExclude,
+		FlagPermanentDelegate: PolicyExclude,
+		FlagNonTransferable:   PolicyExclude,
+	}
+}
+
+// SPL mint layout sizes, and where Token-2022 extensions start
+const (
+	mintSize            = 82
+	mintExtensionsStart = tokenAccountSize + 1 // After the account type byte
+	accountTypeMint     = 1
+)
+
+// Token-2022 extension types
+const (
+	extensionTransferFeeConfig = 1
+	extensionNonTransferable   = 9
+	extensionPermanentDelegate = 12
+	extensionTransferHook      = 14
+)
+
+// TransferFee is one transfer fee setting of a Token-2022 mint, effective
+// from its epoch on
+type TransferFee struct {
+	Epoch       uint64 `json:"epoch"`
+	MaximumFee  uint64 `json:"maximumFee"` // Raw token units
+	BasisPoints uint16 `json:"basisPoints"`
+}
+
+// MintInfo is the decoded state of a mint account
+type MintInfo struct {
+	Mint            string
+	Token2022       bool
+	Decimals        int
+	MintAuthority   *solana.PublicKey
+	FreezeAuthority *solana.PublicKey
+	Supply          uint64
+
+	// Token-2022 extensions
+	OlderTransferFee  *TransferFee
+	NewerTransferFee  *TransferFee
+	TransferHook      *solana.PublicKey // Hook program
+	PermanentDelegate *solana.PublicKey
+	NonTransferable   bool
+}
+
+// Flags returns the risk flags of the mint, sorted
+func (m *MintInfo) Flags() []string {
+	var flags []string
+	if m.FreezeAuthority != nil {
+		flags = append(flags, FlagFreezeAuthority)
+	}
+	if m.MintAuthority != nil {
+		flags = append(flags, FlagMintAuthority)
+	}
+	if m.NewerTransferFee != nil && (m.NewerTransferFee.BasisPoints > 0 || m.OlderTransferFee.BasisPoints > 0) {
+		flags = append(flags, FlagTransferFee)
+	}
+	if m.TransferHook != nil {
+		flags = append(flags, FlagTransferHook)
+	}
+	if m.PermanentDelegate != nil {
+		flags = append(flags, FlagPermanentDelegate)
+	}
+	if m.NonTransferable {
+		flags = append(flags, FlagNonTransferable)
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// tokenProgram returns the program owning a mint: Token-2022 for configured
+// tokens the mint inspection found to be Token-2022 mints, SPL Token otherwise
+func tokenProgram(tokens map[string]TokenConfig, mint string) solana.PublicKey {
+	for _, token := range tokens {
+		if token.Mint == mint && token.Info != nil && token.Info.Token2022 {
+			return solana.Token2022ProgramID
+		}
+	}
+	return solana.TokenProgramID
+}
+
+// associatedTokenAddress derives owner's associated token account for a mint
+// of the given token program
+func associatedTokenAddress(owner, mint, program solana.PublicKey) (solana.PublicKey, error) {
+	ata, _, err := solana.FindProgramAddress([][]byte{owner[:], program[:], mint[:]}, solana.SPLAssociatedTokenAccountProgramID)
+	return ata, err
+}
+
+// decodeMint decodes an SPL Token or Token-2022 mint account
+func decodeMint(mint string, owner solana.PublicKey, data []byte) (*MintInfo, error) {
+	if len(data) < mintSize {
+		return nil, fmt.Errorf("data too short for mint: %d bytes", len(data))
+	}
+
+	info := &MintInfo{
+		Mint:            mint,
+		Token2022:       owner.Equals(solana.Token2022ProgramID),
+		MintAuthority:   decodeOptionalKey(data[0:36]),
+		Supply:          binary.LittleEndian.Uint64(data[36:44]),
+		Decimals:        int(data[44]),
+		FreezeAuthority: decodeOptionalKey(data[46:82]),
+	}
+	if data[45] != 1 {
+		return nil, fmt.Errorf("mint is not initialized")
+	}
+
+	if !info.Token2022 || len(data) <= mintExtensionsStart {
+		return info, nil
+	}
+	if data[tokenAccountSize] != accountTypeMint {
+		return nil, fmt.Errorf("account type %d is not a mint", data[tokenAccountSize])
+	}
+
+	// Type-length-value extension entries
+	for offset := mintExtensionsStart; offset+4 <= len(data); {
+		kind := binary.LittleEndian.Uint16(data[offset : offset+2])
+		length := int(binary.LittleEndian.Uint16(data[offset+2 : offset+4]))
+		offset += 4
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("extension %d overruns the account", kind)
+		}
+		value := data[offset : offset+length]
+		offset += length
+
+		switch kind {
+		case extensionTransferFeeConfig:
+			// Two authorities and the withheld amount precede the fee settings
+			if len(value) < 108 {
+				return nil, fmt.Errorf("transfer fee config too short")
+			}
+			info.OlderTransferFee = decodeTransferFee(value[72:90])
+			info.NewerTransferFee = decodeTransferFee(value[90:108])
+		case extensionNonTransferable:
+			info.NonTransferable = true
+		case extensionPermanentDelegate:
+			if len(value) >= 32 {
+				info.PermanentDelegate = nonZeroKey(value[0:32])
+			}
+		case extensionTransferHook:
+			// The hook authority precedes the hook program
+			if len(value) >= 64 {
+				info.TransferHook = nonZeroKey(value[32:64])
+			}
+		}
+	}
+	return info, nil
+}
+
+// decodeOptionalKey decodes a COption<Pubkey>
+func decodeOptionalKey(data []byte) *solana.PublicKey {
+	if binary.LittleEndian.Uint32(data[0:4]) == 0 {
+		return nil
+	}
+	key := solana.PublicKeyFromBytes(data[4:36])
+	return &key
+}
+
+// nonZeroKey decodes an OptionalNonZeroPubkey
+func nonZeroKey(data []byte) *solana.PublicKey {
+	key := solana.PublicKeyFromBytes(data)
+	if key.IsZero() {
+		return nil
+	}
+	return &key
+}
+
+// decodeTransferFee decodes an epoch, maximum fee and basis points triple
+func decodeTransferFee(data []byte) *TransferFee {
+	return &TransferFee{
+		Epoch:       binary.LittleEndian.Uint64(data[0:8]),
+		MaximumFee:  binary.LittleEndian.Uint64(data[8:16]),
+		BasisPoints: binary.LittleEndian.Uint16(data[16:18]),
+	}
+}
+
+// inspectMints fetches and decodes the mint of every configured token
+func inspectMints(ctx context.Context, client *rpc.Client, tokens map[string]TokenConfig) (map[string]*MintInfo, error) {
+	names := make([]string, 0, len(tokens))
+	mints := make([]solana.PublicKey, 0, len(tokens))
+	for name, token := range tokens {
+		mint, err := solana.PublicKeyFromBase58(token.Mint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mint %s: %v", token.Mint, err)
+		}
+		names = append(names, name)
+		mints = append(mints, mint)
+	}
+
+	result, err := client.GetMultipleAccounts(ctx, mints...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch mints: %v", err)
+	}
+
+	infos := make(map[string]*MintInfo, len(names))
+	for i, account := range result.Value {
+		if account == nil {
+			return nil, fmt.Errorf("mint %s of %s does not exist", mints[i], names[i])
+		}
+		info, err := decodeMint(mints[i].String(), account.Owner, account.Data.GetBinary())
+		if err != nil {
+			return nil, fmt.Errorf("mint %s of %s: %v", mints[i], names[i], err)
+		}
+		infos[names[i]] = info
+	}
+	return infos, nil
+}
+
+// applyMintPolicy inspects the mints of the configured tokens, records their
+// flags on the tokens and drops pools trading a token with an excluded flag
+func applyMintPolicy(ctx context.Context, client *rpc.Client, cfg *Config) error {
+	infos, err := inspectMints(ctx, client, cfg.Tokens)
+	if err != nil {
+		return err
+	}
+
+	excluded := make(map[string][]string)
+	for name, info := range infos {
+		token := cfg.Tokens[name]
+		if info.Decimals != token.Decimals {
+			log.Printf("Warning: %s is configured with %d decimals, its mint has %d", name, token.Decimals, info.Decimals)
+		}
+
+		token.Flags = nil
+		for _, flag := range info.Flags() {
+			switch cfg.MintPolicy[flag] {
+			case PolicyExclude:
+				excluded[name] = append(excluded[name], flag)
+			case PolicyTag:
+				token.Flags = append(token.Flags, flag)
+			}
+		}
+		token.Info = info
+		cfg.Tokens[name] = token
+
+		if flags := info.Flags(); len(flags) > 0 {
+			log.Printf("Token %s (%s): %v", name, info.Mint, flags)
+		}
+	}
+
+	pools := cfg.Pools[:0]
+	for _, pool := range cfg.Pools {
+		var reasons []string
+		for _, token := range []string{pool.BaseToken, pool.QuoteToken} {
+			for _, flag := range excluded[token] {
+				reasons = append(reasons, token+" "+flag)
+			}
+		}
+		if len(reasons) > 0 {
+			log.Printf("Excluding pool %s: %v", pool.Name, reasons)
+			continue
+		}
+		pools = append(pools, pool)
+	}
+	cfg.Pools = pools
+	return nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OpportunityHop is one swap of an opportunity at its optimal input size
+type OpportunityHop struct {
+	Pool       string    `json:"pool"`
+	Dex        string    `json:"dex"`
+	Direction  Direction `json:"direction"`
+	InputMint  string    `json:"inputMint"`
+	OutputMint string    `json:"outputMint"`
+	Rate       float64   `json:"rate"` // Spot rate after the pool fee
+	Fee        float64   `json:"fee"`
+	AmountIn   float64   `json:"amountIn"` // Raw input token units
+	AmountOut  float64   `json:"amountOut"`
+	Slot       uint64    `json:"slot"`
+}
+
+// Opportunity is a profitable cycle sized for execution. Amounts and profits
+// are in raw units of the start token.
+type Opportunity struct {
+	ID          string           `json:"id"`
+	Hops        []OpportunityHop `json:"hops"`
+	StartToken  string           `json:"startToken"`
+	StartMint   string           `json:"startMint"`
+	InputAmount float64          `json:"inputAmount"`
+	GrossProfit float64          `json:"grossProfit"` // Output minus input, after pool fees
+	NetProfit   float64          `json:"netProfit"`   // Gross profit minus execution costs
+	SpotProfit  float64          `json:"spotProfitPercent"`
+	Flags       []string         `json:"flags,omitempty"` // Tagged risk flags of the tokens traded
+
+	// Execution costs, and profits in UI units of the common quote token
+	ComputeUnits     uint64  `json:"computeUnits"`
+	CostLamports     uint64  `json:"costLamports"`
+	QuoteToken       string  `json:"quoteToken"`
+	InputQuote       float64 `json:"inputQuote"` // Notional of the input amount
+	GrossProfitQuote float64 `json:"grossProfitQuote"`
+	CostQuote        float64 `json:"costQuote"`
+	NetProfitQuote   float64 `json:"netProfitQuote"`
+
+	MinSlot    uint64    `json:"minSlot"`
+	MaxSlot    uint64    `json:"maxSlot"`
+	DetectedAt time.Time `json:"detectedAt"`
+
+	cycle Cycle
+}
+
+// Cycle returns the cycle the opportunity was built from
+func (o *Opportunity) Cycle() Cycle {
+	return o.cycle
+}
+
+// mintValue is a mint whose balance an opportunity's transaction changes and
+// what a raw unit of it is worth in the start token
+type mintValue struct {
+	mint  string
+	value float64
+}
+
+// mintValues returns the start mint and the intermediate mints of the
+// opportunity, which keep what their hops return above the minimum, valued
+// at the mid rates of the cycle. A single swap, such as a rebalance, ends in
+// another token, valued at the mid rate of the swap.
+func (o *Opportunity) mintValues() []mintValue {
+	values := []mintValue{{mint: o.StartMint, value: 1}}
+	if len(o.Hops) == 0 || len(o.cycle) != len(o.Hops) {
+		return values
+	}
+	seen := map[string]bool{o.StartMint: true}
+	for i, hop := range o.Hops[:len(o.Hops)-1] {
+		if !seen[hop.OutputMint] {
+			seen[hop.OutputMint] = true
+			values = append(values, mintValue{mint: hop.OutputMint, value: o.cycle.StartValue(i, 1)})
+		}
+	}
+	if last := o.Hops[len(o.Hops)-1]; !seen[last.OutputMint] {
+		values = append(values, mintValue{mint: last.OutputMint, value: 1 / o.cycle[len(o.cycle)-1].MidRate()})
+	}
+	return values
+}
+
+// newOpportunity sizes a cycle with the constant product state of its pools
+// and returns it as an opportunity. It reports false if no input size is
+// profitable once price impact is taken into account.
+func newOpportunity(cycle Cycle, tokens map[string]TokenConfig, detectedAt time.Time) (*Opportunity, bool) {
+	if len(cycle) == 0 {
+		return nil, false
+	}
+
+	input, ok := optimalCycleInput(cycle)
+	if !ok {
+		return nil, false
+	}
+
+	opp := &Opportunity{
+		Hops:        make([]OpportunityHop, 0, len(cycle)),
+		StartToken:  cycle[0].From,
+		StartMint:   tokens[cycle[0].From].Mint,
+		InputAmount: input,
+		SpotProfit:  cycle.ProfitPercent(),
+		DetectedAt:  detectedAt,
+		cycle:       cycle,
+	}
+
+	amount := input
+	for i, hop := range cycle {
+		out := hop.Out(amount)
+		opp.Hops = append(opp.Hops, OpportunityHop{
+			Pool:       hop.Pool,
+			Dex:        hop.Dex,
+			Direction:  hop.Direction,
+			InputMint:  tokens[hop.From].Mint,
+			OutputMint: tokens[hop.To].Mint,
+			Rate:       hop.Rate,
+			Fee:        hop.Fee,
+			AmountIn:   amount,
+			AmountOut:  out,
+			Slot:       hop.Slot,
+		})
+		if i == 0 || hop.Slot < opp.MinSlot {
+			opp.MinSlot = hop.Slot
+		}
+		if hop.Slot > opp.MaxSlot {
+			opp.MaxSlot = hop.Slot
+		}
+		amount = out
+
+		for _, flag := range tokens[hop.From].Flags {
+			if !slices.Contains(opp.Flags, flag) {
+				opp.Flags = append(opp.Flags, flag)
+			}
+		}
+	}
+
+	opp.GrossProfit = amount - input
+	// Execution costs are deducted by applyCostModel
+	opp.NetProfit = opp.GrossProfit
+	if opp.GrossProfit <= 0 {
+		return nil, false
+	}
+
+	// The same cycle on the same pool state always gets the same ID
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s@%d-%d", cycle.Key(), opp.MinSlot, opp.MaxSlot)))
+	opp.ID = hex.EncodeToString(sum[:8])
+
+	return opp, true
+}
+
+// constantProductOut returns the output of a constant product swap
+func constantProductOut(amountIn, reserveIn, reserveOut, fee float64) float64 {
+	effective := amountIn * (1 - fee)
+	return reserveOut * effective / (reserveIn + effective)
+}
+
+// optimalCycleInput returns the input that maximizes the profit of a cycle of
+// constant product pools. Chained swaps compose into a single function
+// out(x) = a*x / (b + c*x), whose profit out(x) - x peaks at
+// x = (sqrt(a*b) - b) / c. Transfer fees scale each hop's input and output,
+// which keeps that form.
+func optimalCycleInput(cycle Cycle) (float64, bool) {
+	a, b, c := 1.0, 1.0, 0.0
+	for _, hop := range cycle {
+		if hop.ReserveIn <= 0 || hop.ReserveOut <= 0 {
+			return 0, false
+		}
+		gamma := (1 - hop.Fee) * (1 - hop.TransferFeeIn)
+		keep := 1 - hop.TransferFeeOut
+		// Compose out_hop(y) = keep*gamma*Rout*y / (Rin + gamma*y) with y = a*x/(b+c*x)
+		a, b, c = keep*gamma*hop.ReserveOut*a, hop.ReserveIn*b, hop.ReserveIn*c+gamma*a
+	}
+
+	if a <= b || c <= 0 {
+		return 0, false
+	}
+	input := (math.Sqrt(a*b) - b) / c
+	if input <= 0 || math.IsInf(input, 0) || math.IsNaN(input) {
+		return 0, false
+	}
+	return input, true
+}
+
+// OpportunityJournal appends detected opportunities to a JSON lines file
+type OpportunityJournal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenOpportunityJournal opens or creates the journal at path
+func OpenOpportunityJournal(path string) (*OpportunityJournal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal %s: %v", path, err)
+	}
+	return &OpportunityJournal{file: file}, nil
+}
+
+// Record appends one opportunity to the journal
+func (j *OpportunityJournal) Record(opp *Opportunity) error {
+	return j.record("", opp)
+}
+
+// RecordSimulation appends the outcome of a simulation to the journal
+func (j *OpportunityJournal) RecordSimulation(result *SimulationResult) error {
+	return j.record("simulation", result)
+}
+
+// RecordLanding appends the on-chain outcome of an opportunity to the journal
+func (j *OpportunityJournal) RecordLanding(landing *Landing) error {
+	return j.record("landing", landing)
+}
+
+// RecordAlert appends a risk alert to the journal
+func (j *OpportunityJournal) RecordAlert(alert *RiskAlert) error {
+	return j.record("alert", alert)
+}
+
+// record appends one line to the journal, v wrapped in an object under kind
+// so the entries can be told apart. Opportunities have no kind and are
+// written as they are.
+func (j *OpportunityJournal) record(kind string, v any) error {
+	name := kind
+	if kind == "" {
+		name = "opportunity"
+	} else {
+		v = map[string]any{kind: v}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", name, err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return nil
+}
+
+// Close closes the journal file
+func (j *OpportunityJournal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,143 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Jupiter Price API V2 endpoint and the most mints it prices per request
+const (
+	DefaultJupiterEndpoint = "https://api.jup.ag/price/v2"
+	jupiterPriceIDLimit    = 100
+)
+
+// priceInfo is the price of one mint in a Price API V2 response
+type priceInfo struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Price string `json:"price"`
+}
+
+// priceResponse is a Price API V2 response. Mints Jupiter has no price for
+// map to null.
+type priceResponse struct {
+	Data      map[string]*priceInfo `json:"data"`
+	TimeTaken float64               `json:"timeTaken"`
+}
+
+// cachedPrice is a price, nil if Jupiter has none, and when it was fetched
+type cachedPrice struct {
+	price     *big.Rat
+	fetchedAt time.Time
+}
+
+// JupiterPriceOracle fetches USD prices from Jupiter's Price API V2,
+// batching mints up to the API's limit and caching prices for a TTL
+type JupiterPriceOracle struct {
+	endpoint string
+	http     *http.Client
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedPrice
+}
+
+// NewJupiterPriceOracle creates an oracle querying endpoint, usually
+// DefaultJupiterEndpoint, and caching prices for ttl
+func NewJupiterPriceOracle(endpoint string, timeout, ttl time.Duration) *JupiterPriceOracle {
+	return &JupiterPriceOracle{
+		endpoint: endpoint,
+		http:     &http.Client{Timeout: timeout},
+		ttl:      ttl,
+		cache:    make(map[string]cachedPrice),
+	}
+}
+
+// Prices returns the USD price of the mints, fetching those not cached
+// within the TTL. Mints Jupiter has no price for are cached as such too.
+func (o *JupiterPriceOracle) Prices(ctx context.Context, mints []string) (map[string]*big.Rat, error) {
+	prices := make(map[string]*big.Rat, len(mints))
+	var missing []string
+
+	now := time.Now()
+	o.mu.Lock()
+	for _, mint := range mints {
+		cached, ok := o.cache[mint]
+		switch {
+		case !ok || now.Sub(cached.fetchedAt) >= o.ttl:
+			missing = append(missing, mint)
+		case cached.price != nil:
+			prices[mint] = new(big.Rat).Set(cached.price)
+		}
+	}
+	o.mu.Unlock()
+
+	for start := 0; start < len(missing); start += jupiterPriceIDLimit {
+		batch := missing[start:min(start+jupiterPriceIDLimit, len(missing))]
+		fetched, err := o.fetch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		o.mu.Lock()
+		for _, mint := range batch {
+			price := fetched[mint]
+			o.cache[mint] = cachedPrice{price: price, fetchedAt: now}
+			if price != nil {
+				prices[mint] = new(big.Rat).Set(price)
+			}
+		}
+		o.mu.Unlock()
+	}
+	return prices, nil
+}
+
+// fetch requests the prices of one batch of mints
+func (o *JupiterPriceOracle) fetch(ctx context.Context, mints []string) (map[string]*big.Rat, error) {
+	query := url.Values{}
+	query.Set("ids", strings.Join(mints, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create price request: %v", err)
+	}
+
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send price request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price request: HTTP error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var priceResp priceResponse
+	if err := json.Unmarshal(body, &priceResp); err != nil {
+		return nil, fmt.Errorf("failed to decode price response: %v", err)
+	}
+
+	prices := make(map[string]*big.Rat, len(priceResp.Data))
+	for mint, info := range priceResp.Data {
+		if info == nil {
+			continue
+		}
+		price, err := parsePrice(info.Price)
+		if err != nil {
+			return nil, fmt.Errorf("price of %s: %v", mint, err)
+		}
+		prices[mint] = price
+	}
+	return prices, nil
+}
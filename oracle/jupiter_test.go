@@ -0,0 +1,165 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newPriceStub serves the Price API V2 with the price price returns for each
+// requested id, null if it returns "", and counts the requests
+func newPriceStub(t *testing.T, price func(id string) string) (string, *atomic.Int32, *[][]string) {
+	t.Helper()
+	var requests atomic.Int32
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		ids := strings.Split(r.URL.Query().Get("ids"), ",")
+		batches = append(batches, ids)
+
+		entries := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if p := price(id); p != "" {
+				entries = append(entries, fmt.Sprintf(`%q: {"id": %q, "type": "derivedPrice", "price": %q}`, id, id, p))
+			} else {
+				entries = append(entries, fmt.Sprintf(`%q: null`, id))
+			}
+		}
+		fmt.Fprintf(w, `{"data": {%s}, "timeTaken": 0.001}`, strings.Join(entries, ", "))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &requests, &batches
+}
+
+func TestJupiterPriceOracleBatches(t *testing.T) {
+	endpoint, requests, batches := newPriceStub(t, func(id string) string { return "1" })
+	o := NewJupiterPriceOracle(endpoint, time.Second, time.Minute)
+
+	mints := make([]string, 250)
+	for i := range mints {
+		mints[i] = fmt.Sprintf("mint%d", i)
+	}
+	prices, err := o.Prices(context.Background(), mints)
+	if err != nil {
+		t.Fatalf("Prices: %v", err)
+	}
+	if len(prices) != len(mints) {
+		t.Errorf("got %d prices, want %d", len(prices), len(mints))
+	}
+	if requests.Load() != 3 {
+		t.Fatalf("sent %d requests, want 3", requests.Load())
+	}
+	for i, want := range []int{100, 100, 50} {
+		if len((*batches)[i]) != want {
+			t.Errorf("batch %d has %d ids, want %d", i+1, len((*batches)[i]), want)
+		}
+	}
+}
+
+func TestJupiterPriceOracleParsesDecimals(t *testing.T) {
+	endpoint, _, _ := newPriceStub(t, func(id string) string { return "0.000012345678901234567891" })
+	o := NewJupiterPriceOracle(endpoint, time.Second, time.Minute)
+
+	prices, err := o.Prices(context.Background(), []string{"mint"})
+	if err != nil {
+		t.Fatalf("Prices: %v", err)
+	}
+	want, _ := new(big.Rat).SetString("12345678901234567891/1000000000000000000000000")
+	if prices["mint"] == nil || prices["mint"].Cmp(want) != 0 {
+		t.Errorf("price = %v, want exactly %v", prices["mint"], want)
+	}
+}
+
+func TestJupiterPriceOracleTTL(t *testing.T) {
+	endpoint, requests, _ := newPriceStub(t, func(id string) string { return "2.5" })
+	o := NewJupiterPriceOracle(endpoint, time.Second, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if _, err := o.Prices(context.Background(), []string{"mint"}); err != nil {
+			t.Fatalf("Prices: %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("sent %d requests within the TTL, want 1", requests.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := o.Prices(context.Background(), []string{"mint"}); err != nil {
+		t.Fatalf("Prices: %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("sent %d requests after the TTL, want 2", requests.Load())
+	}
+}
+
+func TestJupiterPriceOracleNullPrices(t *testing.T) {
+	endpoint, requests, _ := newPriceStub(t, func(id string) string {
+		if id == "unknown" {
+			return ""
+		}
+		return "1.25"
+	})
+	o := NewJupiterPriceOracle(endpoint, time.Second, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		prices, err := o.Prices(context.Background(), []string{"known", "unknown"})
+		if err != nil {
+			t.Fatalf("Prices: %v", err)
+		}
+		if _, ok := prices["unknown"]; ok || prices["known"] == nil {
+			t.Errorf("prices = %v, want only the known mint", prices)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("sent %d requests, want the missing price cached for the TTL", requests.Load())
+	}
+}
+
+func TestJupiterPriceOracleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		err     string
+	}{
+		{
+			name: "HTTP error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "rate limited", http.StatusTooManyRequests)
+			},
+			err: "HTTP error 429: rate limited",
+		},
+		{
+			name: "invalid price",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"data": {"mint": {"id": "mint", "type": "derivedPrice", "price": "abc"}}}`)
+			},
+			err: `invalid price "abc"`,
+		},
+		{
+			name: "invalid JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"data": `)
+			},
+			err: "failed to decode price response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			o := NewJupiterPriceOracle(server.URL, time.Second, time.Minute)
+			_, err := o.Prices(context.Background(), []string{"mint"})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package oracle provides token prices for the acc_parser tools and anything
+// else that needs USD prices by mint
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// PriceOracle returns the USD prices of tokens by mint
+type PriceOracle interface {
+	// Prices returns the price of every requested mint the oracle knows.
+	// Mints without a price are left out of the result.
+	Prices(ctx context.Context, mints []string) (map[string]*big.Rat, error)
+}
+
+// StaticPriceOracle serves fixed prices, for tests and offline runs
+type StaticPriceOracle struct {
+	prices map[string]*big.Rat
+}
+
+// NewStaticPriceOracle parses decimal price strings by mint
+func NewStaticPriceOracle(prices map[string]string) (*StaticPriceOracle, error) {
+	parsed := make(map[string]*big.Rat, len(prices))
+	for mint, price := range prices {
+		value, err := parsePrice(price)
+		if err != nil {
+			return nil, fmt.Errorf("price of %s: %v", mint, err)
+		}
+		parsed[mint] = value
+	}
+	return &StaticPriceOracle{prices: parsed}, nil
+}
+
+// Prices returns the configured prices of the mints
+func (o *StaticPriceOracle) Prices(ctx context.Context, mints []string) (map[string]*big.Rat, error) {
+	prices := make(map[string]*big.Rat, len(mints))
+	for _, mint := range mints {
+		if price, ok := o.prices[mint]; ok {
+			prices[mint] = new(big.Rat).Set(price)
+		}
+	}
+	return prices, nil
+}
+
+// PriceInQuote returns how many quote tokens one base token is worth
+func PriceInQuote(prices map[string]*big.Rat, base, quote string) (*big.Rat, error) {
+	basePrice, ok := prices[base]
+	if !ok {
+		return nil, fmt.Errorf("no price for %s", base)
+	}
+	quotePrice, ok := prices[quote]
+	if !ok || quotePrice.Sign() == 0 {
+		return nil, fmt.Errorf("no price for %s", quote)
+	}
+	return new(big.Rat).Quo(basePrice, quotePrice), nil
+}
+
+// parsePrice parses a decimal price string exactly
+func parsePrice(price string) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(price)
+	if !ok {
+		return nil, fmt.Errorf("invalid price %q", price)
+	}
+	if value.Sign() < 0 {
+		return nil, fmt.Errorf("negative price %q", price)
+	}
+	return value, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// PaperConfig controls paper trading against the modeled pool reserves
+type PaperConfig struct {
+	Enabled        bool               `json:"enabled"`
+	Balances       map[string]float64 `json:"balances"`       // Starting portfolio in UI units by token
+	SummarySeconds int                `json:"summarySeconds"` // Interval of the periodic summary, 0 for none
+}
+
+// PaperTrade is the outcome of paper executing one opportunity
+type PaperTrade struct {
+	OpportunityID string
+	Executed      bool
+	Reason        string  // Why the trade was skipped or reverted
+	Input         float64 // Raw start token units
+	Output        float64
+	ModelProfit   float64 // Net profit the model predicts for Input, in the quote token
+	NetProfit     float64 // Net profit realized, in the quote token
+}
+
+// paperCycleStats accumulates the trades of one cycle
+type paperCycleStats struct {
+	path      string
+	trades    int
+	hits      int
+	netProfit float64
+}
+
+// PaperTrader executes opportunities against the pool reserves of their
+// detection snapshot and keeps a virtual portfolio
+type PaperTrader struct {
+	tokens      map[string]TokenConfig
+	costs       CostConfig
+	slippageBps uint64
+	quoteToken  string
+	nativeToken string
+
+	mu       sync.Mutex
+	started  time.Time
+	initial  map[string]float64 // Raw units by token
+	balances map[string]float64
+	trades   int
+	hits     int
+	reverted int
+	skipped  map[string]int
+	model    float64 // Sum of modeled net profits of attempted trades
+	realized float64
+	cycles   map[string]*paperCycleStats
+}
+
+// NewPaperTrader creates a paper trader holding the configured balances
+func NewPaperTrader(cfg *Config) *PaperTrader {
+	nativeToken, _ := tokenByMint(cfg.Tokens, solana.SolMint.String())
+	p := &PaperTrader{
+		tokens:      cfg.Tokens,
+		costs:       cfg.Costs,
+		slippageBps: cfg.Execution.SlippageBps,
+		quoteToken:  cfg.QuoteToken,
+		nativeToken: nativeToken,
+		started:     time.Now(),
+		initial:     make(map[string]float64),
+		balances:    make(map[string]float64),
+		skipped:     make(map[string]int),
+		cycles:      make(map[string]*paperCycleStats),
+	}
+	for token, amount := range cfg.Paper.Balances {
+		raw := math.Floor(amount * math.Pow10(cfg.Tokens[token].Decimals))
+		p.initial[token] = raw
+		p.balances[token] = raw
+	}
+	return p
+}
+
+// Execute trades the opportunity with the portfolio's balance, swapping
+// through the reserves its cycle was detected on with the integer amounts
+// and minimum outputs of swapAmounts, as the transaction builder does. What
+// an intermediate hop returns above its minimum stays in the portfolio and
+// counts towards the profit. Reverted trades still pay the transaction fees,
+// but not the Jito tip.
+func (p *PaperTrader) Execute(opp *Opportunity, snap *GraphSnapshot) PaperTrade {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	trade := PaperTrade{OpportunityID: opp.ID}
+	cycle := opp.Cycle()
+
+	trade.Input = math.Min(math.Floor(opp.InputAmount), p.balances[opp.StartToken])
+	if trade.Input < 1 {
+		return p.skip(trade, "no "+opp.StartToken+" balance")
+	}
+	if p.balances[p.nativeToken] < float64(opp.CostLamports) {
+		return p.skip(trade, "insufficient SOL for fees")
+	}
+
+	// Costs the final hop must cover, as in the transaction builder
+	costs := math.Max(opp.GrossProfit-opp.NetProfit, 0)
+
+	// The hops for the input actually traded, which is smaller than the
+	// opportunity's when the balance does not cover it
+	amounts := swapAmounts(uint64(trade.Input), cycle, p.slippageBps, costs)
+	trade.ModelProfit = p.toQuote(amounts[len(amounts)-1].Out-trade.Input, opp.StartToken, snap) -
+		p.toQuote(float64(opp.CostLamports), p.nativeToken, snap)
+	for i, amount := range amounts[:len(amounts)-1] {
+		trade.ModelProfit += p.toQuote(amount.Out-float64(amount.MinOut), cycle[i].To, snap)
+	}
+
+	var amount float64
+	leftovers := make([]float64, len(cycle)-1)
+	for i, hop := range cycle {
+		out := math.Floor(hop.Out(float64(amounts[i].AmountIn)))
+		if minOut := float64(amounts[i].MinOut); out < minOut {
+			trade.Reason = fmt.Sprintf("hop %d returned %.0f, below minimum %.0f", i+1, out, minOut)
+			p.reverted++
+			p.balances[p.nativeToken] -= float64(opp.CostLamports - p.costs.JitoTipLamports)
+			trade.NetProfit = -p.toQuote(float64(opp.CostLamports-p.costs.JitoTipLamports), p.nativeToken, snap)
+			p.record(cycle, trade, false)
+			return trade
+		}
+		if i < len(leftovers) {
+			leftovers[i] = out - float64(amounts[i].MinOut)
+		}
+		amount = out
+	}
+
+	trade.Executed = true
+	trade.Output = amount
+	p.balances[opp.StartToken] += trade.Output - trade.Input
+	p.balances[p.nativeToken] -= float64(opp.CostLamports)
+	trade.NetProfit = p.toQuote(trade.Output-trade.Input, opp.StartToken, snap) -
+		p.toQuote(float64(opp.CostLamports), p.nativeToken, snap)
+	for i, leftover := range leftovers {
+		p.balances[cycle[i].To] += leftover
+		trade.NetProfit += p.toQuote(leftover, cycle[i].To, snap)
+	}
+	p.record(cycle, trade, trade.NetProfit > 0)
+	return trade
+}
+
+// skip counts a trade that was not attempted
+func (p *PaperTrader) skip(trade PaperTrade, reason string) PaperTrade {
+	trade.Reason = reason
+	p.skipped[reason]++
+	return trade
+}
+
+// record adds an attempted trade to the statistics
+func (p *PaperTrader) record(cycle Cycle, trade PaperTrade, hit bool) {
+	p.trades++
+	if hit {
+		p.hits++
+	}
+	p.model += trade.ModelProfit
+	p.realized += trade.NetProfit
+
+	key := cycle.Key()
+	stats, ok := p.cycles[key]
+	if !ok {
+		stats = &paperCycleStats{path: strings.Join(cycle.Path(), " -> ")}
+		p.cycles[key] = stats
+	}
+	stats.trades++
+	if hit {
+		stats.hits++
+	}
+	stats.netProfit += trade.NetProfit
+}
+
+// toQuote values a raw token amount in UI units of the quote token
+func (p *PaperTrader) toQuote(amount float64, token string, snap *GraphSnapshot) float64 {
+	value, ok := snap.ConvertRaw(amount, token, p.quoteToken)
+	if !ok {
+		return 0
+	}
+	return value / math.Pow10(p.tokens[p.quoteToken].Decimals)
+}
+
+// Summary describes the trades and the portfolio so far
+func (p *PaperTrader) Summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Paper trading summary after %v\n", time.Since(p.started).Round(time.Second))
+
+	hitRate := 0.0
+	if p.trades > 0 {
+		hitRate = float64(p.hits) / float64(p.trades) * 100
+	}
+	fmt.Fprintf(&b, "Trades: %d, profitable: %d (%.1f%%), reverted: %d\n", p.trades, p.hits, hitRate, p.reverted)
+	for reason, count := range p.skipped {
+		fmt.Fprintf(&b, "Skipped (%s): %d\n", reason, count)
+	}
+
+	fmt.Fprintf(&b, "PnL: %.6f %s realized, %.6f modeled", p.realized, p.quoteToken, p.model)
+	if p.model != 0 {
+		fmt.Fprintf(&b, " (slippage vs model %.2f%%)", (p.realized-p.model)/math.Abs(p.model)*100)
+	}
+	b.WriteString("\n")
+
+	cycles := make([]*paperCycleStats, 0, len(p.cycles))
+	for _, stats := range p.cycles {
+		cycles = append(cycles, stats)
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].netProfit > cycles[j].netProfit })
+	for _, stats := range cycles {
+		fmt.Fprintf(&b, "  %s: %d trades, %d profitable, %.6f %s\n",
+			stats.path, stats.trades, stats.hits, stats.netProfit, p.quoteToken)
+	}
+
+	tokens := make([]string, 0, len(p.balances))
+	for token := range p.balances {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	b.WriteString("Portfolio:")
+	for _, token := range tokens {
+		scale := math.Pow10(p.tokens[token].Decimals)
+		fmt.Fprintf(&b, " %s %.6f (%+.6f)", token, p.balances[token]/scale, (p.balances[token]-p.initial[token])/scale)
+	}
+	return b.String()
+}
+
+// reportPeriodically logs a summary every interval until done is closed
+func reportPeriodically(summary func() string, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			log.Print(summary())
+		}
+	}
+}
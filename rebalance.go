@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// RebalanceConfig keeps the wallet's inventory close to target weights, as
+// cycles anchored in one token drain the others over time
+type RebalanceConfig struct {
+	Enabled         bool               `json:"enabled"`
+	Targets         map[string]float64 `json:"targets"`         // Share of the inventory value by token, summing to 1
+	Threshold       float64            `json:"threshold"`       // Drift of a share from its target that triggers a swap
+	IntervalSeconds int                `json:"intervalSeconds"` // How often the balances are checked
+}
+
+// RebalancePlan is the swap bringing the most overweight token back towards
+// its target
+type RebalancePlan struct {
+	From     string
+	To       string
+	Drift    float64      // Share of the inventory value From holds above its target
+	AmountIn float64      // Raw From units
+	Swap     *Opportunity // Single hop through the best pool with its costs, nil if no pool trades the pair
+	MinOut   uint64
+}
+
+// Rebalancer watches the wallet's balances and swaps between the target
+// tokens when their weights drift too far
+type Rebalancer struct {
+	cfg         RebalanceConfig
+	tokens      map[string]TokenConfig
+	pools       []PoolConfig
+	quoteToken  string
+	costs       CostConfig
+	slippageBps uint64
+	maxNotional float64 // Risk limit on a trade's input value, in UI units of the quote token
+	graph       *Graph
+	executor    *Executor
+}
+
+// NewRebalancer creates a rebalancer sending its swaps through executor
+func NewRebalancer(cfg *Config, graph *Graph, executor *Executor) *Rebalancer {
+	return &Rebalancer{
+		cfg:         cfg.Rebalance,
+		tokens:      cfg.Tokens,
+		pools:       cfg.Pools,
+		quoteToken:  cfg.QuoteToken,
+		costs:       cfg.Costs,
+		slippageBps: cfg.Execution.SlippageBps,
+		maxNotional: cfg.Risk.MaxTradeNotional,
+		graph:       graph,
+		executor:    executor,
+	}
+}
+
+// Run checks the balances every interval until ctx is done
+func (r *Rebalancer) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(r.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.check(ctx); err != nil {
+				log.Printf("Failed to rebalance: %v", err)
+			}
+		}
+	}
+}
+
+// check plans a rebalance from the current balances and executes it. Swaps
+// are skipped while a risk limit halts execution.
+func (r *Rebalancer) check(ctx context.Context) error {
+	if r.executor.Risk().Halted() {
+		return nil
+	}
+
+	tokens := make(map[string]TokenConfig, len(r.cfg.Targets))
+	for token := range r.cfg.Targets {
+		tokens[token] = r.tokens[token]
+	}
+	balances, err := fetchWalletBalances(ctx, r.executor.client, r.executor.Wallet(), tokens)
+	if err != nil {
+		return err
+	}
+
+	snap := r.graph.Snapshot()
+	plan, err := r.Plan(balances, snap)
+	if err != nil || plan == nil {
+		return err
+	}
+	if router := r.executor.JupiterRouter(); router != nil {
+		if err := r.compareJupiter(ctx, router, plan, snap); err != nil {
+			log.Printf("Rebalancing without Jupiter: %v", err)
+		}
+	}
+	if plan.Swap == nil {
+		return fmt.Errorf("no pool swaps %s for %s", plan.From, plan.To)
+	}
+
+	swap := plan.Swap
+	route := "Jupiter"
+	if pool := swap.Hops[0].Pool; pool != "" {
+		route = poolNames(r.pools, []string{pool})
+	}
+	log.Printf("Rebalancing: %s is %.1f points over its target, swapping %.0f for at least %d %s through %s (%.6f %s worth, costing %.6f)",
+		plan.From, plan.Drift*100, swap.InputAmount, plan.MinOut, plan.To, route,
+		swap.InputQuote, r.quoteToken, -swap.NetProfitQuote)
+	return r.executor.ExecuteSwap(ctx, swap, plan.MinOut)
+}
+
+// Plan values the balances at the snapshot's mid prices and, if a token's
+// share drifted from its target by the threshold, returns the swap of the
+// most overweight token into the most underweight one through the pool
+// paying the most for it, without a swap if no pool trades the pair. It
+// returns nil if no rebalance is needed.
+func (r *Rebalancer) Plan(balances *WalletBalances, snap *GraphSnapshot) (*RebalancePlan, error) {
+	names := make([]string, 0, len(r.cfg.Targets))
+	for token := range r.cfg.Targets {
+		names = append(names, token)
+	}
+	sort.Strings(names)
+
+	scale := math.Pow10(r.tokens[r.quoteToken].Decimals)
+	raw := make(map[string]float64, len(names))
+	values := make(map[string]float64, len(names))
+	var total float64
+	for _, token := range names {
+		if account := balances.Tokens[token]; account != nil {
+			raw[token] = float64(account.Amount())
+		}
+		value, ok := snap.ConvertRaw(raw[token], token, r.quoteToken)
+		if !ok {
+			return nil, fmt.Errorf("cannot value %s in %s", token, r.quoteToken)
+		}
+		values[token] = value / scale
+		total += values[token]
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	var from, to string
+	var over, under float64
+	for _, token := range names {
+		drift := values[token]/total - r.cfg.Targets[token]
+		if from == "" || drift > over {
+			from, over = token, drift
+		}
+		if to == "" || drift < under {
+			to, under = token, drift
+		}
+	}
+	if over < r.cfg.Threshold && -under < r.cfg.Threshold {
+		return nil, nil
+	}
+
+	// Move as much value as brings either side back to its target, within the
+	// trade size limit so the swap does not trip it and halt execution. Larger
+	// drifts take several checks.
+	move := math.Min(over, -under) * total
+	if r.maxNotional > 0 {
+		move = math.Min(move, r.maxNotional)
+	}
+	amountIn, ok := snap.ConvertRaw(move*scale, r.quoteToken, from)
+	if !ok {
+		return nil, fmt.Errorf("cannot value %s in %s", r.quoteToken, from)
+	}
+	amountIn = math.Floor(math.Min(amountIn, raw[from]))
+	if amountIn <= 0 {
+		return nil, nil
+	}
+
+	var best Hop
+	var bestOut float64
+	for _, edge := range snap.Edges {
+		if edge.From != from || edge.To != to {
+			continue
+		}
+		hop := hopFromEdge(edge)
+		if out := hop.Out(amountIn); out > bestOut {
+			best, bestOut = hop, out
+		}
+	}
+
+	plan := &RebalancePlan{From: from, To: to, Drift: over, AmountIn: amountIn}
+	if bestOut > 0 {
+		if err := r.setSwap(plan, best, bestOut, snap); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// compareJupiter quotes the plan's swap on Jupiter and routes it there if
+// Jupiter pays more than the best pool in the graph
+func (r *Rebalancer) compareJupiter(ctx context.Context, router *JupiterClient, plan *RebalancePlan, snap *GraphSnapshot) error {
+	quote, err := router.Quote(ctx, JupiterQuoteRequest{
+		InputMint:   r.tokens[plan.From].Mint,
+		OutputMint:  r.tokens[plan.To].Mint,
+		Amount:      uint64(plan.AmountIn),
+		SlippageBps: r.slippageBps,
+	})
+	if err != nil {
+		return err
+	}
+	out := float64(quote.OutAmount)
+	if plan.Swap != nil && out <= plan.Swap.Hops[0].AmountOut {
+		return nil
+	}
+
+	hop := Hop{From: plan.From, To: plan.To, Rate: out / plan.AmountIn, Dex: "jupiter"}
+	return r.setSwap(plan, hop, out, snap)
+}
+
+// setSwap sets the plan's swap through hop and its minimum output
+func (r *Rebalancer) setSwap(plan *RebalancePlan, hop Hop, amountOut float64, snap *GraphSnapshot) error {
+	swap, err := r.swapOpportunity(hop, plan.AmountIn, amountOut, snap)
+	if err != nil {
+		return err
+	}
+	plan.Swap = swap
+	plan.MinOut = uint64(math.Floor(amountOut * float64(10000-r.slippageBps) / 10000))
+	return nil
+}
+
+// swapOpportunity describes a rebalancing swap as a single hop opportunity,
+// so it is built, limited and journaled like one. A hop without a pool is
+// routed through Jupiter. Its profits are the value it loses to fees and
+// price impact, in the quote token.
+func (r *Rebalancer) swapOpportunity(hop Hop, amountIn, amountOut float64, snap *GraphSnapshot) (*Opportunity, error) {
+	now := time.Now()
+	swap := &Opportunity{
+		ID: fmt.Sprintf("rebalance-%s-%s-%d", hop.From, hop.To, now.Unix()),
+		Hops: []OpportunityHop{{
+			Pool:       hop.Pool,
+			Dex:        hop.Dex,
+			Direction:  hop.Direction,
+			InputMint:  r.tokens[hop.From].Mint,
+			OutputMint: r.tokens[hop.To].Mint,
+			Rate:       hop.Rate,
+			Fee:        hop.Fee,
+			AmountIn:   amountIn,
+			AmountOut:  amountOut,
+			Slot:       hop.Slot,
+		}},
+		StartToken:  hop.From,
+		StartMint:   r.tokens[hop.From].Mint,
+		InputAmount: amountIn,
+		MinSlot:     hop.Slot,
+		MaxSlot:     hop.Slot,
+		DetectedAt:  now,
+		cycle:       Cycle{hop},
+	}
+
+	nativeToken, ok := tokenByMint(r.tokens, solana.SolMint.String())
+	if !ok {
+		return nil, fmt.Errorf("native SOL is not a configured token")
+	}
+	swap.ComputeUnits = r.costs.ComputeUnits([]string{hop.Dex})
+	swap.CostLamports = r.costs.CostLamports(swap.ComputeUnits)
+
+	scale := math.Pow10(r.tokens[r.quoteToken].Decimals)
+	inputQuote, okIn := snap.ConvertRaw(amountIn, hop.From, r.quoteToken)
+	outputQuote, okOut := snap.ConvertRaw(amountOut, hop.To, r.quoteToken)
+	costQuote, okCost := snap.ConvertRaw(float64(swap.CostLamports), nativeToken, r.quoteToken)
+	if !okIn || !okOut || !okCost {
+		return nil, fmt.Errorf("cannot value the swap of %s for %s in %s", hop.From, hop.To, r.quoteToken)
+	}
+	swap.QuoteToken = r.quoteToken
+	swap.InputQuote = inputQuote / scale
+	swap.GrossProfitQuote = (outputQuote - inputQuote) / scale
+	swap.CostQuote = costQuote / scale
+	swap.NetProfitQuote = swap.GrossProfitQuote - swap.CostQuote
+	return swap, nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RiskConfig limits automatic execution. A limit of 0 is disabled. Once any
+// limit trips, execution halts until it is reset.
+type RiskConfig struct {
+	MaxTradeNotional       float64            `json:"maxTradeNotional"` // Input value per trade, in UI units of the quote token
+	MaxTradesPerMinute     int                `json:"maxTradesPerMinute"`
+	MaxDailyLoss           float64            `json:"maxDailyLoss"`           // Realized loss per UTC day, in UI units of the quote token
+	MaxConsecutiveFailures int                `json:"maxConsecutiveFailures"` // Landings in a row that failed, dropped or could not be reconciled
+	MaxTokenExposure       map[string]float64 `json:"maxTokenExposure"`       // UI units of each token in unresolved trades
+
+	StatePath    string `json:"statePath"`    // Where the halt and the daily PnL survive restarts
+	AlertWebhook string `json:"alertWebhook"` // URL alerts are posted to as JSON, if set
+}
+
+// RiskState is the part of the risk guard kept across restarts
+type RiskState struct {
+	Halted   bool      `json:"halted"`
+	Limit    string    `json:"limit,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	HaltedAt time.Time `json:"haltedAt"`
+	Day      string    `json:"day"`      // UTC date DailyPnL accrues to
+	DailyPnL float64   `json:"dailyPnl"` // Realized profit of the day's landings
+}
+
+// RiskStatus is the state of the risk guard reported by the control API
+type RiskStatus struct {
+	RiskState
+	TradesLastMinute    int                `json:"tradesLastMinute"`
+	ConsecutiveFailures int                `json:"consecutiveFailures"`
+	Exposure            map[string]float64 `json:"exposure"`
+}
+
+// RiskAlert is emitted when a limit trips
+type RiskAlert struct {
+	Time          time.Time `json:"time"`
+	Limit         string    `json:"limit"`
+	Reason        string    `json:"reason"`
+	OpportunityID string    `json:"opportunityId,omitempty"`
+}
+
+// errExecutionHalted is returned for trades refused because execution halted
+var errExecutionHalted = errors.New("execution halted")
+
+// RiskGuard checks every trade against the risk limits before it is sent and
+// halts execution when one of them trips
+type RiskGuard struct {
+	cfg     RiskConfig
+	tokens  map[string]TokenConfig
+	journal *OpportunityJournal
+	webhook *http.Client
+
+	mu       sync.Mutex
+	state    RiskState
+	trades   []time.Time          // Trades sent within the last minute
+	reserved map[string]time.Time // When each unresolved trade was counted, by opportunity ID
+	failures int
+	exposure map[string]float64            // UI units by token
+	pending  map[string]map[string]float64 // Exposure of each unresolved trade, by opportunity ID
+}
+
+// NewRiskGuard creates a guard, restoring the state saved by a previous run
+func NewRiskGuard(cfg *Config, journal *OpportunityJournal) (*RiskGuard, error) {
+	g := &RiskGuard{
+		cfg:      cfg.Risk,
+		tokens:   cfg.Tokens,
+		journal:  journal,
+		webhook:  &http.Client{Timeout: 10 * time.Second},
+		exposure: make(map[string]float64),
+		reserved: make(map[string]time.Time),
+		pending:  make(map[string]map[string]float64),
+	}
+
+	if g.cfg.StatePath != "" {
+		data, err := os.ReadFile(g.cfg.StatePath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read risk state %s: %v", g.cfg.StatePath, err)
+		default:
+			if err := json.Unmarshal(data, &g.state); err != nil {
+				return nil, fmt.Errorf("failed to parse risk state %s: %v", g.cfg.StatePath, err)
+			}
+		}
+	}
+	if g.state.Halted {
+		log.Printf("Execution halted since %s by %s: %s. Reset through the control API or with -reset-risk",
+			g.state.HaltedAt.Format(time.RFC3339), g.state.Limit, g.state.Reason)
+	}
+	return g, nil
+}
+
+// Halted reports whether execution is halted
+func (g *RiskGuard) Halted() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state.Halted
+}
+
+// Reserve checks a trade against the limits before it is sent and, if it
+// passes, counts it and adds its inputs to the exposure until it resolves. A
+// trade breaching a limit is refused and halts execution.
+func (g *RiskGuard) Reserve(opp *Opportunity) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.state.Halted {
+		return fmt.Errorf("%w by %s: %s", errExecutionHalted, g.state.Limit, g.state.Reason)
+	}
+
+	now := time.Now()
+	g.pruneTrades(now)
+
+	if g.cfg.MaxTradeNotional > 0 && opp.InputQuote > g.cfg.MaxTradeNotional {
+		return g.trip("maxTradeNotional", opp.ID, "trade of %.6f %s exceeds %.6f",
+			opp.InputQuote, opp.QuoteToken, g.cfg.MaxTradeNotional)
+	}
+	if g.cfg.MaxTradesPerMinute > 0 && len(g.trades) >= g.cfg.MaxTradesPerMinute {
+		return g.trip("maxTradesPerMinute", opp.ID, "%d trades sent within a minute", len(g.trades))
+	}
+
+	exposure := g.tradeExposure(opp)
+	for token, amount := range exposure {
+		limit, ok := g.cfg.MaxTokenExposure[token]
+		if ok && g.exposure[token]+amount > limit {
+			return g.trip("maxTokenExposure", opp.ID, "%.6f %s in unresolved trades would exceed %.6f",
+				g.exposure[token]+amount, token, limit)
+		}
+	}
+
+	g.trades = append(g.trades, now)
+	g.reserved[opp.ID] = now
+	for token, amount := range exposure {
+		g.exposure[token] += amount
+	}
+	g.pending[opp.ID] = exposure
+	return nil
+}
+
+// Release removes the exposure of a trade that was not sent after all and no
+// longer counts it towards the trades per minute
+func (g *RiskGuard) Release(opp *Opportunity) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if at, ok := g.reserved[opp.ID]; ok {
+		if i := slices.Index(g.trades, at); i >= 0 {
+			g.trades = slices.Delete(g.trades, i, i+1)
+		}
+	}
+	g.release(opp.ID)
+}
+
+// RecordLanding resolves a trade, adds its realized profit to the day and
+// halts execution if the daily loss or the consecutive failures limit trips
+func (g *RiskGuard) RecordLanding(landing *Landing) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.release(landing.OpportunityID)
+
+	if day := time.Now().UTC().Format(time.DateOnly); day != g.state.Day {
+		g.state.Day = day
+		g.state.DailyPnL = 0
+	}
+	g.state.DailyPnL += landing.RealizedProfit
+
+	if landing.Status == "landed" {
+		g.failures = 0
+	} else {
+		g.failures++
+	}
+
+	switch {
+	case g.state.Halted:
+	case g.cfg.MaxDailyLoss > 0 && -g.state.DailyPnL > g.cfg.MaxDailyLoss:
+		g.trip("maxDailyLoss", landing.OpportunityID, "realized %.6f today, more than %.6f lost",
+			g.state.DailyPnL, g.cfg.MaxDailyLoss)
+	case g.cfg.MaxConsecutiveFailures > 0 && g.failures >= g.cfg.MaxConsecutiveFailures:
+		g.trip("maxConsecutiveFailures", landing.OpportunityID, "%d landings in a row were not confirmed successful", g.failures)
+	default:
+		g.save()
+	}
+}
+
+// Reset clears a halt, the consecutive failures and the day's loss so
+// execution resumes
+func (g *RiskGuard) Reset(by string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.state.Halted {
+		log.Printf("Execution resumed by %s, was halted by %s: %s", by, g.state.Limit, g.state.Reason)
+	}
+	g.state.Halted = false
+	g.state.Limit = ""
+	g.state.Reason = ""
+	g.state.HaltedAt = time.Time{}
+	g.state.DailyPnL = math.Max(g.state.DailyPnL, 0)
+	g.failures = 0
+	g.trades = nil
+	g.save()
+}
+
+// Status returns the current state of the guard
+func (g *RiskGuard) Status() RiskStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pruneTrades(time.Now())
+	exposure := make(map[string]float64, len(g.exposure))
+	for token, amount := range g.exposure {
+		exposure[token] = amount
+	}
+	return RiskStatus{
+		RiskState:           g.state,
+		TradesLastMinute:    len(g.trades),
+		ConsecutiveFailures: g.failures,
+		Exposure:            exposure,
+	}
+}
+
+// tradeExposure returns the UI amount of every token the trade's hops take
+// as input
+func (g *RiskGuard) tradeExposure(opp *Opportunity) map[string]float64 {
+	exposure := make(map[string]float64)
+	for _, hop := range opp.Hops {
+		token, ok := tokenByMint(g.tokens, hop.InputMint)
+		if !ok {
+			continue
+		}
+		exposure[token] += hop.AmountIn / math.Pow10(g.tokens[token].Decimals)
+	}
+	return exposure
+}
+
+// release removes the exposure of a trade. The caller holds the lock.
+func (g *RiskGuard) release(id string) {
+	for token, amount := range g.pending[id] {
+		g.exposure[token] -= amount
+		if g.exposure[token] <= 1e-12 {
+			delete(g.exposure, token)
+		}
+	}
+	delete(g.pending, id)
+	delete(g.reserved, id)
+}
+
+// pruneTrades forgets trades older than a minute. The caller holds the lock.
+func (g *RiskGuard) pruneTrades(now time.Time) {
+	i := sort.Search(len(g.trades), func(i int) bool { return now.Sub(g.trades[i]) < time.Minute })
+	g.trades = g.trades[i:]
+}
+
+// trip halts execution, saves the state and emits an alert. The caller holds
+// the lock. It returns the error refusing the trade that tripped it.
+func (g *RiskGuard) trip(limit, opportunityID, format string, args ...any) error {
+	alert := &RiskAlert{
+		Time:          time.Now(),
+		Limit:         limit,
+		Reason:        fmt.Sprintf(format, args...),
+		OpportunityID: opportunityID,
+	}
+	g.state.Halted = true
+	g.state.Limit = alert.Limit
+	g.state.Reason = alert.Reason
+	g.state.HaltedAt = alert.Time
+	g.save()
+
+	log.Printf("ALERT: execution halted by %s: %s", alert.Limit, alert.Reason)
+	if g.journal != nil {
+		if err := g.journal.RecordAlert(alert); err != nil {
+			log.Printf("Failed to journal alert: %v", err)
+		}
+	}
+	if g.cfg.AlertWebhook != "" {
+		go g.postAlert(alert)
+	}
+	return fmt.Errorf("%w by %s: %s", errExecutionHalted, alert.Limit, alert.Reason)
+}
+
+// postAlert posts an alert to the configured webhook
+func (g *RiskGuard) postAlert(alert *RiskAlert) {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Failed to encode alert: %v", err)
+		return
+	}
+	resp, err := g.webhook.Post(g.cfg.AlertWebhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to post alert: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Failed to post alert: webhook returned %s", resp.Status)
+	}
+}
+
+// save writes the state file. The caller holds the lock.
+func (g *RiskGuard) save() {
+	if g.cfg.StatePath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(g.state, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode risk state: %v", err)
+		return
+	}
+	// Written aside and renamed so a crash cannot leave a partial file
+	tmp := g.cfg.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("Failed to write risk state: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, g.cfg.StatePath); err != nil {
+		log.Printf("Failed to write risk state: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Default environment variable holding a base58 private key
+const defaultSignerEnv = "SOLANA_PRIVATE_KEY"
+
+// SignerConfig selects where the wallet's private key lives
+type SignerConfig struct {
+	Type string `json:"type"` // "keypair", "env" or "remote"
+	Path string `json:"path"` // Solana CLI JSON keypair file
+	Env  string `json:"env"`  // Variable holding a base58 private key
+	URL  string `json:"url"`  // Remote signer endpoint
+}
+
+// Signer signs transaction messages for one wallet
+type Signer interface {
+	PublicKey() solana.PublicKey
+	Sign(ctx context.Context, message []byte) (solana.Signature, error)
+}
+
+// NewSigner creates the signer described by cfg. Remote signers sign for
+// wallet; local keys must match wallet if it is set.
+func NewSigner(cfg SignerConfig, wallet string) (Signer, error) {
+	switch cfg.Type {
+	case "keypair":
+		key, err := solana.PrivateKeyFromSolanaKeygenFile(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keypair %s: %v", cfg.Path, err)
+		}
+		return checkWallet(&keySigner{key: key}, wallet)
+	case "env":
+		name := cfg.Env
+		if name == "" {
+			name = defaultSignerEnv
+		}
+		encoded := os.Getenv(name)
+		if encoded == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		key, err := solana.PrivateKeyFromBase58(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in %s: %v", name, err)
+		}
+		return checkWallet(&keySigner{key: key}, wallet)
+	case "remote":
+		pubKey, err := solana.PublicKeyFromBase58(wallet)
+		if err != nil {
+			return nil, fmt.Errorf("remote signer needs the wallet public key: %v", err)
+		}
+		return &remoteSigner{
+			url:    cfg.URL,
+			pubKey: pubKey,
+			http:   &http.Client{Timeout: 5 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown signer type %q", cfg.Type)
+	}
+}
+
+// checkWallet makes sure a local key belongs to the configured wallet
+func checkWallet(signer Signer, wallet string) (Signer, error) {
+	if wallet != "" && signer.PublicKey().String() != wallet {
+		return nil, fmt.Errorf("signer key %s does not match wallet %s", signer.PublicKey(), wallet)
+	}
+	return signer, nil
+}
+
+// keySigner signs with a private key held in memory
+type keySigner struct {
+	key solana.PrivateKey
+}
+
+func (s *keySigner) PublicKey() solana.PublicKey {
+	return s.key.PublicKey()
+}
+
+func (s *keySigner) Sign(ctx context.Context, message []byte) (solana.Signature, error) {
+	return s.key.Sign(message)
+}
+
+// remoteSigner asks an HTTP service holding the key to sign. It posts
+// {"publicKey", "message"} with the message base64 encoded and expects
+// {"signature"} in base58.
+type remoteSigner struct {
+	url    string
+	pubKey solana.PublicKey
+	http   *http.Client
+}
+
+func (s *remoteSigner) PublicKey() solana.PublicKey {
+	return s.pubKey
+}
+
+func (s *remoteSigner) Sign(ctx context.Context, message []byte) (solana.Signature, error) {
+	body, err := json.Marshal(map[string]string{
+		"publicKey": s.pubKey.String(),
+		"message":   base64.StdEncoding.EncodeToString(message),
+	})
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to encode signing request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to create signing request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to reach remote signer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to read signer response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return solana.Signature{}, fmt.Errorf("remote signer: HTTP error %d: %s", resp.StatusCode, string(data))
+	}
+
+	var result struct {
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to decode signer response: %v", err)
+	}
+	signature, err := solana.SignatureFromBase58(result.Signature)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("invalid signature from remote signer: %v", err)
+	}
+
+	// A signer answering for another key would only be caught on chain
+	if !signature.Verify(s.pubKey, message) {
+		return solana.Signature{}, fmt.Errorf("remote signer returned a signature not valid for %s", s.pubKey)
+	}
+	return signature, nil
+}
+
+// signTransaction signs a transaction whose only required signer is the
+// signer's wallet
+func signTransaction(ctx context.Context, signer Signer, tx *solana.Transaction) error {
+	if tx.Message.Header.NumRequiredSignatures != 1 || !tx.Message.AccountKeys[0].Equals(signer.PublicKey()) {
+		return fmt.Errorf("transaction needs signers besides %s", signer.PublicKey())
+	}
+
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %v", err)
+	}
+	signature, err := signer.Sign(ctx, message)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	tx.Signatures = []solana.Signature{signature}
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	"solana-arbitrage/tokenaccount"
+)
+
+// SimulationResult is the outcome of simulating an opportunity's transaction
+type SimulationResult struct {
+	OpportunityID  string   `json:"opportunityId"`
+	Accepted       bool     `json:"accepted"`
+	Reason         string   `json:"reason,omitempty"` // Why the transaction was rejected
+	ExpectedProfit float64  `json:"expectedProfit"`   // Raw start token units
+	RealizedProfit float64  `json:"realizedProfit"`
+	UnitsConsumed  uint64   `json:"unitsConsumed"`
+	Logs           []string `json:"logs,omitempty"`
+}
+
+// Simulator checks transactions against the current chain state before they
+// are sent
+type Simulator struct {
+	client *rpc.Client
+	owner  solana.PublicKey
+	tokens map[string]TokenConfig
+
+	// Share of the expected gross profit the simulation may fall short by
+	tolerance float64
+
+	mu         sync.Mutex
+	rejections map[string]int
+}
+
+// NewSimulator creates a simulator for transactions of owner trading the
+// configured tokens
+func NewSimulator(client *rpc.Client, owner solana.PublicKey, tokens map[string]TokenConfig, tolerance float64) *Simulator {
+	return &Simulator{
+		client:     client,
+		owner:      owner,
+		tokens:     tokens,
+		tolerance:  tolerance,
+		rejections: make(map[string]int),
+	}
+}
+
+// Simulate runs the transaction with the latest blockhash and compares the
+// change of the owner's token balances with the opportunity's model. The
+// start token counts as is, intermediate tokens at the mid rates of the cycle.
+func (s *Simulator) Simulate(ctx context.Context, tx *solana.Transaction, opp *Opportunity) (*SimulationResult, error) {
+	result := &SimulationResult{
+		OpportunityID:  opp.ID,
+		ExpectedProfit: opp.GrossProfit,
+	}
+
+	values := opp.mintValues()
+	accounts := make([]solana.PublicKey, len(values))
+	before := make([]uint64, len(values))
+	for i, value := range values {
+		mint, err := solana.PublicKeyFromBase58(value.mint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mint %s: %v", value.mint, err)
+		}
+		accounts[i], err = associatedTokenAddress(s.owner, mint, tokenProgram(s.tokens, value.mint))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive token account for %s: %v", value.mint, err)
+		}
+		if before[i], err = s.tokenBalance(ctx, accounts[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := s.client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+		Commitment:             rpc.CommitmentProcessed,
+		ReplaceRecentBlockhash: true,
+		Accounts: &rpc.SimulateTransactionAccountsOpts{
+			Encoding:  solana.EncodingBase64,
+			Addresses: accounts,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate transaction for %s: %v", opp.ID, err)
+	}
+	if out.Value == nil {
+		return nil, fmt.Errorf("empty simulation result for %s", opp.ID)
+	}
+
+	sim := out.Value
+	result.Logs = sim.Logs
+	if sim.UnitsConsumed != nil {
+		result.UnitsConsumed = *sim.UnitsConsumed
+	}
+
+	if sim.Err != nil {
+		return s.reject(result, "simulation failed", fmt.Sprintf("%v%s", sim.Err, lastErrorLog(sim.Logs))), nil
+	}
+
+	if len(sim.Accounts) != len(accounts) {
+		return s.reject(result, "no post balance", ""), nil
+	}
+	for i, account := range sim.Accounts {
+		if account == nil || account.Data == nil {
+			return s.reject(result, "no post balance", values[i].mint), nil
+		}
+		after, err := tokenAccountAmount(account.Data.GetBinary())
+		if err != nil {
+			return s.reject(result, "unreadable post balance", err.Error()), nil
+		}
+		result.RealizedProfit += (float64(after) - float64(before[i])) * values[i].value
+	}
+	if result.RealizedProfit < opp.GrossProfit*(1-s.tolerance) {
+		return s.reject(result, "profit short of model", fmt.Sprintf("realized %.0f, expected %.0f",
+			result.RealizedProfit, opp.GrossProfit)), nil
+	}
+
+	result.Accepted = true
+	return result, nil
+}
+
+// Check simulates a transaction that is not an arbitrage, such as a
+// rebalancing swap, and returns why it would fail, if it would
+func (s *Simulator) Check(ctx context.Context, tx *solana.Transaction, id string) error {
+	out, err := s.client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+		Commitment:             rpc.CommitmentProcessed,
+		ReplaceRecentBlockhash: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to simulate transaction for %s: %v", id, err)
+	}
+	if out.Value == nil {
+		return fmt.Errorf("empty simulation result for %s", id)
+	}
+	if out.Value.Err != nil {
+		return fmt.Errorf("simulation of %s failed: %v%s", id, out.Value.Err, lastErrorLog(out.Value.Logs))
+	}
+	return nil
+}
+
+// Rejections returns how often each kind of rejection occurred
+func (s *Simulator) Rejections() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]int, len(s.rejections))
+	for reason, count := range s.rejections {
+		out[reason] = count
+	}
+	return out
+}
+
+// reject marks the result as rejected, counting rejections by kind
+func (s *Simulator) reject(result *SimulationResult, kind, detail string) *SimulationResult {
+	result.Reason = kind
+	if detail != "" {
+		result.Reason += ": " + detail
+	}
+
+	s.mu.Lock()
+	s.rejections[kind]++
+	s.mu.Unlock()
+	return result
+}
+
+// tokenBalance returns the raw balance of a token account, zero if it does
+// not exist yet
+func (s *Simulator) tokenBalance(ctx context.Context, account solana.PublicKey) (uint64, error) {
+	info, err := s.client.GetAccountInfoWithOpts(ctx, account, &rpc.GetAccountInfoOpts{
+		Commitment: rpc.CommitmentProcessed,
+	})
+	if errors.Is(err, rpc.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch token account %s: %v", account, err)
+	}
+	return tokenAccountAmount(info.Value.Data.GetBinary())
+}
+
+// tokenAccountAmount reads the raw amount of an SPL token account
+func tokenAccountAmount(data []byte) (uint64, error) {
+	var account tokenaccount.TokenAccount
+	if err := account.Decode(data); err != nil {
+		return 0, err
+	}
+	return account.Amount(), nil
+}
+
+// lastErrorLog returns the last program log that reports an error, if any
+func lastErrorLog(logs []string) string {
+	for i := len(logs) - 1; i >= 0; i-- {
+		line := strings.ToLower(logs[i])
+		if strings.Contains(line, "error") || strings.Contains(line, "failed") {
+			return " (" + logs[i] + ")"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// rpcStubError is returned by a stub handler to answer with a JSON-RPC error
+type rpcStubError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// newRPCStub serves JSON-RPC requests with the result handle returns for
+// their method and params
+func newRPCStub(t *testing.T, handle func(method string, params json.RawMessage) any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid JSON-RPC request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch result := handle(req.Method, req.Params).(type) {
+		case *rpcStubError:
+			resp["error"] = result
+		default:
+			resp["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// tokenAccountData encodes an initialized SPL token account holding amount
+func tokenAccountData(mint, owner solana.PublicKey, amount uint64) []string {
+	data := make([]byte, 165)
+	copy(data[:32], mint[:])
+	copy(data[32:64], owner[:])
+	binary.LittleEndian.PutUint64(data[64:72], amount)
+	data[108] = 1
+	return []string{base64.StdEncoding.EncodeToString(data), "base64"}
+}
+
+// testTransaction returns an unsigned transaction with a single transfer
+func testTransaction(t *testing.T, payer solana.PublicKey) *solana.Transaction {
+	t.Helper()
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{system.NewTransferInstruction(1000, payer, solana.NewWallet().PublicKey()).Build()},
+		solana.Hash{},
+		solana.TransactionPayer(payer),
+	)
+	if err != nil {
+		t.Fatalf("failed to build transaction: %v", err)
+	}
+	return tx
+}
+
+// checkSimulateOptions reports simulateTransaction params that do not replace
+// the blockhash or do not return the accounts in base64
+func checkSimulateOptions(t *testing.T, params json.RawMessage, accounts ...solana.PublicKey) {
+	t.Helper()
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 2 {
+		t.Errorf("simulateTransaction params = %s", params)
+		return
+	}
+	var opts struct {
+		ReplaceRecentBlockhash bool `json:"replaceRecentBlockhash"`
+		Accounts               *struct {
+			Encoding  string   `json:"encoding"`
+			Addresses []string `json:"addresses"`
+		} `json:"accounts"`
+	}
+	if err := json.Unmarshal(args[1], &opts); err != nil {
+		t.Errorf("simulateTransaction options = %s: %v", args[1], err)
+		return
+	}
+	if !opts.ReplaceRecentBlockhash {
+		t.Errorf("replaceRecentBlockhash not set: %s", args[1])
+	}
+	if opts.Accounts == nil || opts.Accounts.Encoding != "base64" || len(opts.Accounts.Addresses) != len(accounts) {
+		t.Errorf("accounts option = %s, want %d addresses in base64", args[1], len(accounts))
+		return
+	}
+	for i, account := range accounts {
+		if opts.Accounts.Addresses[i] != account.String() {
+			t.Errorf("account %d = %s, want %s", i, opts.Accounts.Addresses[i], account)
+		}
+	}
+}
+
+func TestSimulatorSimulate(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	mint := solana.NewWallet().PublicKey()
+	ata, _, err := solana.FindAssociatedTokenAddress(owner, mint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opp := &Opportunity{ID: "opp", StartMint: mint.String(), GrossProfit: 100}
+
+	account := func(amount uint64) map[string]any {
+		return map[string]any{
+			"data":       tokenAccountData(mint, owner, amount),
+			"executable": false,
+			"lamports":   2039280,
+			"owner":      solana.TokenProgramID.String(),
+			"rentEpoch":  0,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		before   any // getAccountInfo value, nil for a missing account
+		sim      map[string]any
+		accepted bool
+		reason   string
+		realized float64
+	}{
+		{
+			name:     "accepted",
+			before:   account(1000),
+			sim:      map[string]any{"err": nil, "accounts": []any{account(1100)}, "unitsConsumed": 120000},
+			accepted: true,
+			realized: 100,
+		},
+		{
+			name:     "within tolerance from a missing account",
+			sim:      map[string]any{"err": nil, "accounts": []any{account(95)}},
+			accepted: true,
+			realized: 95,
+		},
+		{
+			name: "simulation error",
+			sim: map[string]any{
+				"err":      map[string]any{"InstructionError": []any{2, map[string]any{"Custom": 30}}},
+				"logs":     []string{"Program log: swap", "Program 675k failed: custom program error: 0x1e"},
+				"accounts": nil,
+			},
+			reason: "simulation failed",
+		},
+		{
+			name:   "missing post balance",
+			before: account(1000),
+			sim:    map[string]any{"err": nil, "accounts": []any{nil}},
+			reason: "no post balance",
+		},
+		{
+			name:     "profit short of model",
+			before:   account(1000),
+			sim:      map[string]any{"err": nil, "accounts": []any{account(1050)}},
+			reason:   "profit short of model",
+			realized: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRPCStub(t, func(method string, params json.RawMessage) any {
+				switch method {
+				case "getAccountInfo":
+					return map[string]any{"context": map[string]any{"slot": 1}, "value": tt.before}
+				case "simulateTransaction":
+					checkSimulateOptions(t, params, ata)
+					return map[string]any{"context": map[string]any{"slot": 1}, "value": tt.sim}
+				}
+				return &rpcStubError{Code: -32601, Message: "method not found"}
+			})
+
+			simulator := NewSimulator(rpc.New(server.URL), owner, nil, 0.1)
+			result, err := simulator.Simulate(context.Background(), testTransaction(t, owner), opp)
+			if err != nil {
+				t.Fatalf("Simulate: %v", err)
+			}
+			if result.Accepted != tt.accepted {
+				t.Fatalf("accepted = %v, want %v (reason %q)", result.Accepted, tt.accepted, result.Reason)
+			}
+			if !strings.HasPrefix(result.Reason, tt.reason) {
+				t.Errorf("reason = %q, want prefix %q", result.Reason, tt.reason)
+			}
+			if result.RealizedProfit != tt.realized {
+				t.Errorf("realized profit = %v, want %v", result.RealizedProfit, tt.realized)
+			}
+			if !tt.accepted && simulator.Rejections()[tt.reason] != 1 {
+				t.Errorf("rejections = %v, want one %q", simulator.Rejections(), tt.reason)
+			}
+		})
+	}
+}
+
+func TestSimulatorValuesIntermediateTokens(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	start := solana.NewWallet().PublicKey()
+	middle := solana.NewWallet().PublicKey()
+	startATA, _, _ := solana.FindAssociatedTokenAddress(owner, start)
+	middleATA, _, _ := solana.FindAssociatedTokenAddress(owner, middle)
+
+	// The second hop pays 2 start token units per intermediate unit at mid
+	opp := &Opportunity{
+		ID:          "opp",
+		StartMint:   start.String(),
+		GrossProfit: 100,
+		Hops: []OpportunityHop{
+			{InputMint: start.String(), OutputMint: middle.String()},
+			{InputMint: middle.String(), OutputMint: start.String()},
+		},
+		cycle: Cycle{
+			{From: "A", To: "B", ReserveIn: 1000, ReserveOut: 500},
+			{From: "B", To: "A", ReserveIn: 1000, ReserveOut: 2000},
+		},
+	}
+
+	account := func(mint solana.PublicKey, amount uint64) map[string]any {
+		return map[string]any{
+			"data":       tokenAccountData(mint, owner, amount),
+			"executable": false,
+			"lamports":   2039280,
+			"owner":      solana.TokenProgramID.String(),
+			"rentEpoch":  0,
+		}
+	}
+
+	server := newRPCStub(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "getAccountInfo":
+			var value any
+			if strings.Contains(string(params), startATA.String()) {
+				value = account(start, 1000)
+			}
+			return map[string]any{"context": map[string]any{"slot": 1}, "value": value}
+		case "simulateTransaction":
+			checkSimulateOptions(t, params, startATA, middleATA)
+			return map[string]any{"context": map[string]any{"slot": 1}, "value": map[string]any{
+				"err":      nil,
+				"accounts": []any{account(start, 1080), account(middle, 10)},
+			}}
+		}
+		return &rpcStubError{Code: -32601, Message: "method not found"}
+	})
+
+	simulator := NewSimulator(rpc.New(server.URL), owner, nil, 0.1)
+	result, err := simulator.Simulate(context.Background(), testTransaction(t, owner), opp)
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	if !result.Accepted || result.RealizedProfit != 100 {
+		t.Errorf("accepted = %v, realized profit = %v, want 80 start and 10 intermediate units worth 100",
+			result.Accepted, result.RealizedProfit)
+	}
+}
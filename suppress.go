@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// reportedCycle is the state of a cycle when it was last reported
+type reportedCycle struct {
+	profitPercent float64
+	reportedAt    time.Time
+}
+
+// CycleSuppressor hides cycles that were already reported by an earlier
+// detection pass unless their profit changed materially since
+type CycleSuppressor struct {
+	minChange float64       // Profit change in percentage points that is reported again
+	expiry    time.Duration // How long a report suppresses the same cycle
+	reported  map[string]reportedCycle
+}
+
+// NewCycleSuppressor creates a suppressor reporting profit changes of at
+// least minChange percentage points and forgetting reports after expiry
+func NewCycleSuppressor(minChange float64, expiry time.Duration) *CycleSuppressor {
+	return &CycleSuppressor{
+		minChange: minChange,
+		expiry:    expiry,
+		reported:  make(map[string]reportedCycle),
+	}
+}
+
+// Filter returns the cycles that are new or whose profit changed materially,
+// and records them as reported
+func (s *CycleSuppressor) Filter(cycles []Cycle, now time.Time) []Cycle {
+	for key, r := range s.reported {
+		if now.Sub(r.reportedAt) > s.expiry {
+			delete(s.reported, key)
+		}
+	}
+
+	fresh := make([]Cycle, 0, len(cycles))
+	for _, cycle := range cycles {
+		key := cycle.Key()
+		profit := cycle.ProfitPercent()
+		if last, ok := s.reported[key]; ok && math.Abs(profit-last.profitPercent) < s.minChange {
+			continue
+		}
+		s.reported[key] = reportedCycle{profitPercent: profit, reportedAt: now}
+		fresh = append(fresh, cycle)
+	}
+	return fresh
+}
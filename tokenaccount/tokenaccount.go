@@ -0,0 +1,70 @@
+// Package tokenaccount decodes SPL token accounts for the arbitrage bot and
+// the acc_parser tools
+package tokenaccount
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Size of an SPL token account without extensions
+const Size = 165
+
+// TokenAccount is a decoded SPL token account, shaped like the jsonParsed
+// encoding of the RPC
+type TokenAccount struct {
+	IsNative    bool   `json:"isNative"`
+	Mint        string `json:"mint"`
+	Owner       string `json:"owner"`
+	State       string `json:"state"`
+	TokenAmount struct {
+		Amount         string  `json:"amount"`
+		Decimals       int     `json:"decimals"`
+		UIAmount       float64 `json:"uiAmount"`
+		UIAmountString string  `json:"uiAmountString"`
+	} `json:"tokenAmount"`
+
+	raw uint64
+}
+
+// Decode decodes raw account data into the TokenAccount. UI amounts assume 6
+// decimals until SetDecimals is called with the mint's.
+func (ta *TokenAccount) Decode(data []byte) error {
+	if len(data) < Size {
+		return fmt.Errorf("data too short for token account: %d bytes", len(data))
+	}
+
+	ta.Mint = solana.PublicKeyFromBytes(data[:32]).String()
+	ta.Owner = solana.PublicKeyFromBytes(data[32:64]).String()
+	ta.raw = binary.LittleEndian.Uint64(data[64:72])
+	ta.TokenAmount.Amount = fmt.Sprintf("%d", ta.raw)
+
+	switch data[108] {
+	case 1:
+		ta.State = "initialized"
+	case 2:
+		ta.State = "frozen"
+	default:
+		ta.State = "uninitialized"
+	}
+	// is_native is a COption<u64>, its tag tells whether the account wraps SOL
+	ta.IsNative = binary.LittleEndian.Uint32(data[109:113]) == 1
+
+	ta.SetDecimals(6)
+	return nil
+}
+
+// Amount returns the raw token amount
+func (ta *TokenAccount) Amount() uint64 {
+	return ta.raw
+}
+
+// SetDecimals recomputes the UI amounts with the mint's decimals
+func (ta *TokenAccount) SetDecimals(decimals int) {
+	ta.TokenAmount.Decimals = decimals
+	ta.TokenAmount.UIAmount = float64(ta.raw) / math.Pow10(decimals)
+	ta.TokenAmount.UIAmountString = fmt.Sprintf("%.*f", decimals, ta.TokenAmount.UIAmount)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sync/atomic"
+)
+
+// TradeListConfig restricts the tokens and pools the detector trades
+// through. Entries are token names or mints, and pool names or addresses,
+// and may use path.Match patterns such as "*-GRASS".
+type TradeListConfig struct {
+	Mode          string   `json:"mode"`  // "deny" trades everything not denied, "allow" only allowed tokens
+	Allow         []string `json:"allow"` // Tokens traded in allow mode
+	Deny          []string `json:"deny"`  // Tokens never traded, in either mode
+	DisabledPools []string `json:"disabledPools"`
+}
+
+// TradeFilter decides which tokens and pools may be traded
+type TradeFilter struct {
+	lists  TradeListConfig
+	tokens map[string]TokenConfig
+	pools  map[string]PoolConfig // By address
+}
+
+// NewTradeFilter creates the filter for cfg's trade lists and pool flags
+func NewTradeFilter(cfg *Config) (*TradeFilter, error) {
+	lists := cfg.TradeLists
+	switch lists.Mode {
+	case "", "deny":
+		lists.Mode = "deny"
+	case "allow":
+	default:
+		return nil, fmt.Errorf("unknown trade list mode %q", lists.Mode)
+	}
+	for _, patterns := range [][]string{lists.Allow, lists.Deny, lists.DisabledPools} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	pools := make(map[string]PoolConfig, len(cfg.Pools))
+	for _, pool := range cfg.Pools {
+		pools[pool.Address] = pool
+	}
+	return &TradeFilter{lists: lists, tokens: cfg.Tokens, pools: pools}, nil
+}
+
+// matchAny reports whether any of the values matches any of the patterns
+func matchAny(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if ok, _ := path.Match(pattern, value); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TokenAllowed reports whether the token may be traded
+func (f *TradeFilter) TokenAllowed(name string) bool {
+	mint := f.tokens[name].Mint
+	if matchAny(f.lists.Deny, name, mint) {
+		return false
+	}
+	return f.lists.Mode != "allow" || matchAny(f.lists.Allow, name, mint)
+}
+
+// PoolAllowed reports whether the pool may be traded: it is not disabled and
+// both its tokens are allowed
+func (f *TradeFilter) PoolAllowed(address string) bool {
+	pool, ok := f.pools[address]
+	if !ok || pool.Disabled || matchAny(f.lists.DisabledPools, pool.Name, pool.Address) {
+		return false
+	}
+	return f.TokenAllowed(pool.BaseToken) && f.TokenAllowed(pool.QuoteToken)
+}
+
+// Apply returns a snapshot without the edges of pools that may not be traded
+func (f *TradeFilter) Apply(snap *GraphSnapshot) *GraphSnapshot {
+	edges := make([]Edge, 0, len(snap.Edges))
+	for _, edge := range snap.Edges {
+		if f.PoolAllowed(edge.Pool) {
+			edges = append(edges, edge)
+		}
+	}
+	if len(edges) == len(snap.Edges) {
+		return snap
+	}
+	return newGraphSnapshot(snap.Vertices, edges, snap.Version)
+}
+
+// TradeLists holds the current trade filter, which can be replaced at runtime
+type TradeLists struct {
+	current atomic.Pointer[TradeFilter]
+	changed chan struct{}
+}
+
+// NewTradeLists starts with the given filter
+func NewTradeLists(filter *TradeFilter) *TradeLists {
+	l := &TradeLists{changed: make(chan struct{}, 1)}
+	l.current.Store(filter)
+	return l
+}
+
+// Filter returns the current filter
+func (l *TradeLists) Filter() *TradeFilter {
+	return l.current.Load()
+}
+
+// Update replaces the filter and signals subscribers
+func (l *TradeLists) Update(filter *TradeFilter) {
+	l.current.Store(filter)
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Changed is signalled after the filter was replaced
+func (l *TradeLists) Changed() <-chan struct{} {
+	return l.changed
+}
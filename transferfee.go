@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// How often the current epoch is refreshed; epochs last about two days
+const epochRefresh = 10 * time.Minute
+
+// TransferFeeAt returns the transfer fee in effect at epoch, nil if the mint
+// has none. The newer setting takes over once its epoch is reached.
+func (m *MintInfo) TransferFeeAt(epoch uint64) *TransferFee {
+	if m == nil || m.NewerTransferFee == nil {
+		return nil
+	}
+	if epoch >= m.NewerTransferFee.Epoch {
+		return m.NewerTransferFee
+	}
+	return m.OlderTransferFee
+}
+
+// Amount returns the fee withheld from a transfer of amount raw units
+func (f *TransferFee) Amount(amount uint64) uint64 {
+	if f == nil || f.BasisPoints == 0 {
+		return 0
+	}
+	fee := (amount*uint64(f.BasisPoints) + 9999) / 10000
+	return min(fee, f.MaximumFee)
+}
+
+// TransferFeeSchedule tracks the current epoch to tell which transfer fee
+// each Token-2022 token charges
+type TransferFeeSchedule struct {
+	client *rpc.Client
+	mints  map[string]*MintInfo // By token name, only tokens with a transfer fee config
+	epoch  atomic.Uint64
+	known  atomic.Bool // Whether epoch was fetched yet
+}
+
+// NewTransferFeeSchedule creates a schedule for the inspected tokens
+func NewTransferFeeSchedule(client *rpc.Client, tokens map[string]TokenConfig) *TransferFeeSchedule {
+	mints := make(map[string]*MintInfo)
+	for name, token := range tokens {
+		if token.Info != nil && token.Info.NewerTransferFee != nil {
+			mints[name] = token.Info
+		}
+	}
+	return &TransferFeeSchedule{client: client, mints: mints}
+}
+
+// Refresh fetches the current epoch
+func (s *TransferFeeSchedule) Refresh(ctx context.Context) error {
+	info, err := s.client.GetEpochInfo(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("failed to fetch epoch: %v", err)
+	}
+	previous := s.epoch.Swap(info.Epoch)
+	if wasKnown := s.known.Swap(true); (!wasKnown || previous != info.Epoch) && len(s.mints) > 0 {
+		for name, mint := range s.mints {
+			if fee := mint.TransferFeeAt(info.Epoch); fee != nil {
+				log.Printf("Epoch %d: %s transfer fee %d bps, at most %d", info.Epoch, name, fee.BasisPoints, fee.MaximumFee)
+			}
+		}
+	}
+	return nil
+}
+
+// Run refreshes the epoch until ctx is done
+func (s *TransferFeeSchedule) Run(ctx context.Context) {
+	ticker := time.NewTicker(epochRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh transfer fees: %v", err)
+			}
+		}
+	}
+}
+
+// Rate returns the share of every transfer of token withheld as fee in the
+// current epoch, or the higher of its two fees until the epoch is known. The
+// maximum fee is ignored, so large transfers are charged at most this share.
+func (s *TransferFeeSchedule) Rate(token string) float64 {
+	if s == nil {
+		return 0
+	}
+	mint := s.mints[token]
+	if mint == nil {
+		return 0
+	}
+	fee := mint.NewerTransferFee
+	if s.known.Load() {
+		fee = mint.TransferFeeAt(s.epoch.Load())
+	} else if older := mint.OlderTransferFee; older != nil && older.BasisPoints > fee.BasisPoints {
+		fee = older
+	}
+	if fee == nil {
+		return 0
+	}
+	return float64(fee.BasisPoints) / 10000
+}
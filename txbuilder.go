@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Largest serialized transaction the cluster accepts
+const maxTransactionSize = 1232
+
+var (
+	raydiumAmmV4ProgramID = solana.MustPublicKeyFromBase58("675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8")
+	raydiumAmmV4Authority = solana.MustPublicKeyFromBase58("5Q544fKrFoe6tsEbD7S8EmxGTJYAKtTVhAW5Q5pge4j1")
+	openbookProgramID     = solana.MustPublicKeyFromBase58("srmqPvymJeFKQ4zGQed1GFppgkRHL9kaELCbyksJtPX")
+)
+
+// Raydium AMM v4 instruction tag of swapBaseIn
+const raydiumSwapBaseIn = 9
+
+// RaydiumPoolKeys lists the accounts a Raydium AMM v4 swap needs besides the
+// pool itself
+type RaydiumPoolKeys struct {
+	OpenOrders        string `json:"openOrders"`
+	TargetOrders      string `json:"targetOrders"`
+	BaseVault         string `json:"baseVault"`
+	QuoteVault        string `json:"quoteVault"`
+	MarketProgram     string `json:"marketProgram"` // Defaults to OpenBook
+	Market            string `json:"market"`
+	MarketBids        string `json:"marketBids"`
+	MarketAsks        string `json:"marketAsks"`
+	MarketEventQueue  string `json:"marketEventQueue"`
+	MarketBaseVault   string `json:"marketBaseVault"`
+	MarketQuoteVault  string `json:"marketQuoteVault"`
+	MarketVaultSigner string `json:"marketVaultSigner"`
+}
+
+// TransactionBuilder turns opportunities into single atomic transactions
+type TransactionBuilder struct {
+	owner       solana.PublicKey
+	pools       map[string]PoolConfig
+	tokens      map[string]TokenConfig
+	costs       CostConfig
+	slippageBps uint64
+
+	// Token accounts of the owner known to exist on chain
+	existing map[solana.PublicKey]bool
+
+	// Jito tip appended to every transaction when sending bundles
+	tipLamports uint64
+	tipAccounts []string
+
+	// Address lookup tables v0 transactions are compiled with, if any
+	lookupTables *LookupTableManager
+
+	// Source of the suggested priority fee, and its cap
+	chain          *ChainCache
+	maxPriorityFee uint64
+
+	// Router of swaps without a native instruction, if enabled
+	jupiter *JupiterClient
+}
+
+// SetTip makes every transaction pay a Jito tip to one of the tip accounts
+func (b *TransactionBuilder) SetTip(lamports uint64, tipAccounts []string) {
+	b.tipLamports = lamports
+	b.tipAccounts = tipAccounts
+}
+
+// SetLookupTables makes Build compile versioned transactions using the
+// manager's address lookup tables
+func (b *TransactionBuilder) SetLookupTables(tables *LookupTableManager) {
+	b.lookupTables = tables
+}
+
+// SetJupiter routes swaps through pools the builder has no instruction for,
+// or through no configured pool at all, through Jupiter. Its lookup tables
+// are added to tables.
+func (b *TransactionBuilder) SetJupiter(jupiter *JupiterClient, tables *LookupTableManager) {
+	b.jupiter = jupiter
+	b.lookupTables = tables
+}
+
+// SetPriorityFees makes transactions pay the chain cache's suggested priority
+// fee, up to maxFee micro-lamports per compute unit, instead of the configured one
+func (b *TransactionBuilder) SetPriorityFees(chain *ChainCache, maxFee uint64) {
+	b.chain = chain
+	b.maxPriorityFee = maxFee
+}
+
+// priorityFee returns the priority fee to pay per compute unit
+func (b *TransactionBuilder) priorityFee() uint64 {
+	if b.chain == nil {
+		return b.costs.PriorityFeeMicroLamports
+	}
+	fee, ok := b.chain.PriorityFee()
+	if !ok {
+		return b.costs.PriorityFeeMicroLamports
+	}
+	if b.maxPriorityFee > 0 && fee > b.maxPriorityFee {
+		fee = b.maxPriorityFee
+	}
+	return fee
+}
+
+// NewTransactionBuilder creates a builder for transactions paid and signed by owner
+func NewTransactionBuilder(owner solana.PublicKey, cfg *Config) *TransactionBuilder {
+	pools := make(map[string]PoolConfig)
+	for _, pool := range cfg.Pools {
+		pools[pool.Address] = pool
+	}
+	return &TransactionBuilder{
+		owner:       owner,
+		pools:       pools,
+		tokens:      cfg.Tokens,
+		costs:       cfg.Costs,
+		slippageBps: cfg.Execution.SlippageBps,
+		existing:    make(map[solana.PublicKey]bool),
+	}
+}
+
+// LoadTokenAccounts records which of the owner's associated token accounts
+// for the configured mints already exist, so Build only creates missing ones
+func (b *TransactionBuilder) LoadTokenAccounts(ctx context.Context, client *rpc.Client) error {
+	accounts := make([]solana.PublicKey, 0, len(b.tokens))
+	for _, token := range b.tokens {
+		mint, err := solana.PublicKeyFromBase58(token.Mint)
+		if err != nil {
+			return fmt.Errorf("invalid mint %s: %v", token.Mint, err)
+		}
+		ata, err := associatedTokenAddress(b.owner, mint, tokenProgram(b.tokens, token.Mint))
+		if err != nil {
+			return fmt.Errorf("failed to derive token account for %s: %v", token.Mint, err)
+		}
+		accounts = append(accounts, ata)
+	}
+
+	result, err := client.GetMultipleAccounts(ctx, accounts...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch token accounts: %v", err)
+	}
+	for i, account := range result.Value {
+		b.existing[accounts[i]] = account != nil
+	}
+	return nil
+}
+
+// Build returns an unsigned transaction executing every hop of the
+// opportunity. Intermediate hops require their expected output less the
+// configured slippage and the next hop spends that minimum, and the last hop
+// requires the input back plus the execution costs, so the transaction reverts if the cycle is unprofitable.
+// When tipping is enabled the tip is the last instruction, so it is only paid
+// if the swaps succeed. With lookup tables the transaction is a v0 one
+// referencing the pool accounts through them.
+func (b *TransactionBuilder) Build(ctx context.Context, opp *Opportunity, blockhash solana.Hash) (*solana.Transaction, error) {
+	instructions, err := b.Instructions(ctx, opp)
+	if err != nil {
+		return nil, err
+	}
+	return b.compile(opp.ID, instructions, blockhash)
+}
+
+// BuildSwap returns an unsigned transaction swapping the input of a single
+// hop opportunity, such as a rebalance, for at least minOut
+func (b *TransactionBuilder) BuildSwap(ctx context.Context, opp *Opportunity, minOut uint64, blockhash solana.Hash) (*solana.Transaction, error) {
+	if len(opp.Hops) != 1 {
+		return nil, fmt.Errorf("swap %s has %d hops", opp.ID, len(opp.Hops))
+	}
+	hop := opp.Hops[0]
+
+	instructions := []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(uint32(opp.ComputeUnits)).Build(),
+		computebudget.NewSetComputeUnitPriceInstruction(b.priorityFee()).Build(),
+	}
+	ix, _, err := b.createTokenAccount(hop.OutputMint)
+	if err != nil {
+		return nil, err
+	}
+	if ix != nil {
+		instructions = append(instructions, ix)
+	}
+
+	swap, err := b.swapInstructions(ctx, hop, uint64(math.Floor(opp.InputAmount)), minOut)
+	if err != nil {
+		return nil, fmt.Errorf("swap %s: %v", opp.ID, err)
+	}
+	instructions, err = b.appendTip(append(instructions, swap...))
+	if err != nil {
+		return nil, err
+	}
+	return b.compile(opp.ID, instructions, blockhash)
+}
+
+// compile compiles the instructions into a transaction paid by the owner,
+// using the lookup tables if there are any, and checks its size
+func (b *TransactionBuilder) compile(id string, instructions []solana.Instruction, blockhash solana.Hash) (*solana.Transaction, error) {
+	options := []solana.TransactionOption{solana.TransactionPayer(b.owner)}
+	if b.lookupTables != nil {
+		if tables := b.lookupTables.Tables(); len(tables) > 0 {
+			options = append(options, solana.TransactionAddressTables(tables))
+		}
+	}
+
+	tx, err := solana.NewTransaction(instructions, blockhash, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile transaction for %s: %v", id, err)
+	}
+
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction for %s: %v", id, err)
+	}
+	// Signatures are added later but count towards the limit
+	size := len(data) + len(solana.Signature{})*int(tx.Message.Header.NumRequiredSignatures)
+	if size > maxTransactionSize {
+		return nil, fmt.Errorf("transaction for %s is %d bytes, over the %d byte limit", id, size, maxTransactionSize)
+	}
+
+	return tx, nil
+}
+
+// Instructions returns the instructions of the opportunity's transaction
+func (b *TransactionBuilder) Instructions(ctx context.Context, opp *Opportunity) ([]solana.Instruction, error) {
+	if len(opp.Hops) == 0 {
+		return nil, fmt.Errorf("opportunity %s has no hops", opp.ID)
+	}
+
+	priorityFee := b.priorityFee()
+	instructions := []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(uint32(opp.ComputeUnits)).Build(),
+		computebudget.NewSetComputeUnitPriceInstruction(priorityFee).Build(),
+	}
+
+	// Create the token accounts the swaps write to if they are missing
+	created := make(map[solana.PublicKey]bool)
+	for _, hop := range opp.Hops {
+		for _, mint := range []string{hop.InputMint, hop.OutputMint} {
+			ix, ata, err := b.createTokenAccount(mint)
+			if err != nil {
+				return nil, err
+			}
+			if ix != nil && !created[ata] {
+				created[ata] = true
+				instructions = append(instructions, ix)
+			}
+		}
+	}
+
+	cycle := opp.Cycle()
+	if len(cycle) != len(opp.Hops) {
+		return nil, fmt.Errorf("opportunity %s has no cycle to size its hops with", opp.ID)
+	}
+	amounts := swapAmounts(uint64(math.Floor(opp.InputAmount)), cycle, b.slippageBps, b.landingCost(opp, priorityFee))
+	if last := amounts[len(amounts)-1]; last.Out < float64(last.MinOut) {
+		return nil, fmt.Errorf("opportunity %s returns %.0f from the guaranteed hop outputs, below the %d required",
+			opp.ID, last.Out, last.MinOut)
+	}
+	for i, hop := range opp.Hops {
+		swap, err := b.swapInstructions(ctx, hop, amounts[i].AmountIn, amounts[i].MinOut)
+		if err != nil {
+			return nil, fmt.Errorf("opportunity %s hop %d: %v", opp.ID, i+1, err)
+		}
+		instructions = append(instructions, swap...)
+	}
+
+	return b.appendTip(instructions)
+}
+
+// SwapAmount is what one hop of a transaction spends, the output the model
+// gives for it and the least it must return
+type SwapAmount struct {
+	AmountIn uint64
+	Out      float64
+	MinOut   uint64
+}
+
+// swapAmounts returns the amounts of the hops of a cycle starting with input.
+// Every intermediate hop requires its modeled output less the slippage, and
+// the next hop spends only that minimum, so the transaction never draws on
+// the wallet's balance of an intermediate token. Whatever a hop returns above
+// its minimum stays in the wallet. The final hop requires the input back plus
+// costs.
+func swapAmounts(input uint64, cycle Cycle, slippageBps uint64, costs float64) []SwapAmount {
+	amounts := make([]SwapAmount, len(cycle))
+	amountIn := input
+	for i, hop := range cycle {
+		amounts[i].AmountIn = amountIn
+		amounts[i].Out = hop.Out(float64(amountIn))
+		if i == len(cycle)-1 {
+			amounts[i].MinOut = input + uint64(math.Ceil(costs))
+		} else {
+			amounts[i].MinOut = uint64(math.Floor(amounts[i].Out * float64(10000-slippageBps) / 10000))
+		}
+		amountIn = amounts[i].MinOut
+	}
+	return amounts
+}
+
+// appendTip appends the Jito tip when tipping is enabled
+func (b *TransactionBuilder) appendTip(instructions []solana.Instruction) ([]solana.Instruction, error) {
+	if b.tipLamports == 0 {
+		return instructions, nil
+	}
+	tipAccount, err := randomTipAccount(b.tipAccounts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tip account: %v", err)
+	}
+	return append(instructions, jitoTipInstruction(b.owner, tipAccount, b.tipLamports)), nil
+}
+
+// LookupAccounts returns the accounts worth putting in a lookup table: the
+// programs, the accounts of every configured pool and the owner's token
+// accounts
+func (b *TransactionBuilder) LookupAccounts() ([]solana.PublicKey, error) {
+	accounts := []solana.PublicKey{
+		solana.TokenProgramID,
+		solana.SystemProgramID,
+		solana.SPLAssociatedTokenAccountProgramID,
+		solana.ComputeBudget,
+		raydiumAmmV4ProgramID,
+		raydiumAmmV4Authority,
+		openbookProgramID,
+	}
+
+	for _, token := range b.tokens {
+		ata, err := b.tokenAccount(token.Mint)
+		if err != nil {
+			return nil, err
+		}
+		mint, _ := solana.PublicKeyFromBase58(token.Mint)
+		accounts = append(accounts, mint, ata)
+	}
+
+	for _, pool := range b.pools {
+		keys := pool.Raydium
+		if keys == nil {
+			continue
+		}
+		for _, address := range []string{
+			pool.Address, keys.OpenOrders, keys.TargetOrders, keys.BaseVault, keys.QuoteVault,
+			keys.MarketProgram, keys.Market, keys.MarketBids, keys.MarketAsks, keys.MarketEventQueue,
+			keys.MarketBaseVault, keys.MarketQuoteVault, keys.MarketVaultSigner,
+		} {
+			if address == "" {
+				continue
+			}
+			pubKey, err := solana.PublicKeyFromBase58(address)
+			if err != nil {
+				return nil, fmt.Errorf("pool %s: invalid account key %q: %v", pool.Name, address, err)
+			}
+			accounts = append(accounts, pubKey)
+		}
+	}
+	return accounts, nil
+}
+
+// landingCost returns the cost of landing the opportunity in start token
+// units, rescaled from the cost model if the priority fee paid differs from
+// the modeled one
+func (b *TransactionBuilder) landingCost(opp *Opportunity, priorityFee uint64) float64 {
+	costs := math.Max(opp.GrossProfit-opp.NetProfit, 0)
+	if priorityFee == b.costs.PriorityFeeMicroLamports || opp.CostLamports == 0 {
+		return costs
+	}
+	modeled := float64(opp.CostLamports)
+	paid := modeled + (float64(priorityFee)-float64(b.costs.PriorityFeeMicroLamports))*float64(opp.ComputeUnits)/1e6
+	return costs * math.Max(paid, 0) / modeled
+}
+
+// swapInstructions builds the swap of one hop using the layout of its DEX.
+// Hops through pools without a native swap, or through no pool, are routed
+// through Jupiter when it is enabled.
+func (b *TransactionBuilder) swapInstructions(ctx context.Context, hop OpportunityHop, amountIn, minOut uint64) ([]solana.Instruction, error) {
+	pool, ok := b.pools[hop.Pool]
+	// Raydium AMM v4 only swaps SPL Token mints
+	token2022 := !tokenProgram(b.tokens, hop.InputMint).Equals(solana.TokenProgramID) ||
+		!tokenProgram(b.tokens, hop.OutputMint).Equals(solana.TokenProgramID)
+	switch {
+	case ok && pool.Dex == "raydium-amm-v4" && pool.Raydium != nil && !token2022:
+		ix, err := b.raydiumSwapBaseIn(pool, hop, amountIn, minOut)
+		if err != nil {
+			return nil, err
+		}
+		return []solana.Instruction{ix}, nil
+	case b.jupiter != nil:
+		return b.jupiterSwap(ctx, hop, amountIn, minOut)
+	case !ok:
+		return nil, fmt.Errorf("unknown pool %s", hop.Pool)
+	case pool.Dex == "raydium-amm-v4" && token2022:
+		return nil, fmt.Errorf("pool %s trades a Token-2022 mint, which Raydium AMM v4 cannot swap", pool.Name)
+	case pool.Dex == "raydium-amm-v4":
+		return nil, fmt.Errorf("pool %s has no Raydium account keys configured", pool.Name)
+	default:
+		return nil, fmt.Errorf("unsupported DEX %q for pool %s", pool.Dex, pool.Name)
+	}
+}
+
+// jupiterSwap returns the instructions of Jupiter's route for the hop's
+// tokens. The route is quoted with the slippage that makes its minimum
+// output minOut at the modeled output, and refused if Jupiter's minimum
+// ends up below minOut.
+func (b *TransactionBuilder) jupiterSwap(ctx context.Context, hop OpportunityHop, amountIn, minOut uint64) ([]solana.Instruction, error) {
+	var slippageBps uint64
+	if hop.AmountOut > float64(minOut) {
+		slippageBps = uint64(math.Floor((hop.AmountOut - float64(minOut)) * 10000 / hop.AmountOut))
+	}
+	quote, err := b.jupiter.Quote(ctx, JupiterQuoteRequest{
+		InputMint:   hop.InputMint,
+		OutputMint:  hop.OutputMint,
+		Amount:      amountIn,
+		SlippageBps: slippageBps,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if quote.OtherAmountThreshold < minOut {
+		return nil, fmt.Errorf("Jupiter route %s guarantees %d, below the required %d", quote.Route(), quote.OtherAmountThreshold, minOut)
+	}
+
+	swap, err := b.jupiter.SwapInstructions(ctx, quote, b.owner)
+	if err != nil {
+		return nil, err
+	}
+	if b.lookupTables != nil {
+		if err := b.lookupTables.Ensure(ctx, swap.AddressLookupTableAddresses); err != nil {
+			return nil, err
+		}
+	}
+	return swap.Instructions()
+}
+
+// raydiumSwapBaseIn builds a Raydium AMM v4 swapBaseIn instruction. The swap
+// direction follows from which of the owner's token accounts is the source.
+func (b *TransactionBuilder) raydiumSwapBaseIn(pool PoolConfig, hop OpportunityHop, amountIn, minOut uint64) (solana.Instruction, error) {
+	keys := pool.Raydium
+	if keys == nil {
+		return nil, fmt.Errorf("pool %s has no Raydium account keys configured", pool.Name)
+	}
+
+	source, err := b.tokenAccount(hop.InputMint)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := b.tokenAccount(hop.OutputMint)
+	if err != nil {
+		return nil, err
+	}
+
+	marketProgram := keys.MarketProgram
+	if marketProgram == "" {
+		marketProgram = openbookProgramID.String()
+	}
+
+	accounts := solana.AccountMetaSlice{solana.Meta(solana.TokenProgramID)}
+	for _, key := range []struct {
+		address  string
+		writable bool
+	}{
+		{pool.Address, true},
+		{raydiumAmmV4Authority.String(), false},
+		{keys.OpenOrders, true},
+		{keys.TargetOrders, true},
+		{keys.BaseVault, true},
+		{keys.QuoteVault, true},
+		{marketProgram, false},
+		{keys.Market, true},
+		{keys.MarketBids, true},
+		{keys.MarketAsks, true},
+		{keys.MarketEventQueue, true},
+		{keys.MarketBaseVault, true},
+		{keys.MarketQuoteVault, true},
+		{keys.MarketVaultSigner, false},
+	} {
+		pubKey, err := solana.PublicKeyFromBase58(key.address)
+		if err != nil {
+			return nil, fmt.Errorf("pool %s: invalid account key %q: %v", pool.Name, key.address, err)
+		}
+		meta := solana.Meta(pubKey)
+		if key.writable {
+			meta = meta.WRITE()
+		}
+		accounts = append(accounts, meta)
+	}
+	accounts = append(accounts,
+		solana.Meta(source).WRITE(),
+		solana.Meta(destination).WRITE(),
+		solana.Meta(b.owner).SIGNER(),
+	)
+
+	data := make([]byte, 17)
+	data[0] = raydiumSwapBaseIn
+	binary.LittleEndian.PutUint64(data[1:9], amountIn)
+	binary.LittleEndian.PutUint64(data[9:17], minOut)
+
+	return solana.NewInstruction(raydiumAmmV4ProgramID, accounts, data), nil
+}
+
+// tokenAccount returns the owner's associated token account for a mint
+func (b *TransactionBuilder) tokenAccount(mint string) (solana.PublicKey, error) {
+	mintKey, err := solana.PublicKeyFromBase58(mint)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("invalid mint %s: %v", mint, err)
+	}
+	ata, err := associatedTokenAddress(b.owner, mintKey, tokenProgram(b.tokens, mint))
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to derive token account for %s: %v", mint, err)
+	}
+	return ata, nil
+}
+
+// createTokenAccount returns an idempotent associated token account creation
+// instruction for the mint, or nil if the account is known to exist
+func (b *TransactionBuilder) createTokenAccount(mint string) (solana.Instruction, solana.PublicKey, error) {
+	ata, err := b.tokenAccount(mint)
+	if err != nil {
+		return nil, ata, err
+	}
+	if b.existing[ata] {
+		return nil, ata, nil
+	}
+
+	mintKey := solana.MustPublicKeyFromBase58(mint)
+	accounts := solana.AccountMetaSlice{
+		solana.Meta(b.owner).WRITE().SIGNER(),
+		solana.Meta(ata).WRITE(),
+		solana.Meta(b.owner),
+		solana.Meta(mintKey),
+		solana.Meta(solana.SystemProgramID),
+		solana.Meta(tokenProgram(b.tokens, mint)),
+	}
+	// Instruction 1 of the associated token account program is CreateIdempotent
+	return solana.NewInstruction(solana.SPLAssociatedTokenAccountProgramID, accounts, []byte{1}), ata, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestTokenAccountPrograms(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	legacy := solana.NewWallet().PublicKey()
+	token2022 := solana.NewWallet().PublicKey()
+	b := &TransactionBuilder{
+		owner: owner,
+		tokens: map[string]TokenConfig{
+			"A": {Mint: legacy.String()},
+			"B": {Mint: token2022.String(), Info: &MintInfo{Token2022: true}},
+		},
+		pools:    map[string]PoolConfig{"pool": {Name: "A-B", Dex: "raydium-amm-v4", Raydium: &RaydiumPoolKeys{}}},
+		existing: make(map[solana.PublicKey]bool),
+	}
+
+	want, _, _ := solana.FindAssociatedTokenAddress(owner, legacy)
+	if ata, err := b.tokenAccount(legacy.String()); err != nil || !ata.Equals(want) {
+		t.Errorf("SPL Token account = %s, %v, want %s", ata, err, want)
+	}
+
+	want, _, _ = solana.FindProgramAddress([][]byte{owner[:], solana.Token2022ProgramID[:], token2022[:]},
+		solana.SPLAssociatedTokenAccountProgramID)
+	ix, ata, err := b.createTokenAccount(token2022.String())
+	if err != nil || !ata.Equals(want) {
+		t.Fatalf("Token-2022 account = %s, %v, want %s", ata, err, want)
+	}
+	accounts := ix.Accounts()
+	if program := accounts[len(accounts)-1].PublicKey; !program.Equals(solana.Token2022ProgramID) {
+		t.Errorf("account created with program %s, want Token-2022", program)
+	}
+
+	hop := OpportunityHop{Pool: "pool", InputMint: legacy.String(), OutputMint: token2022.String()}
+	if _, err := b.swapInstructions(context.Background(), hop, 1000, 900); err == nil || !strings.Contains(err.Error(), "Token-2022") {
+		t.Errorf("error = %v, want the Raydium swap of a Token-2022 mint refused", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	"solana-arbitrage/tokenaccount"
+)
+
+// Size of an SPL token account without extensions
+const tokenAccountSize = tokenaccount.Size
+
+// WalletBalances is the SOL and per-token holdings of the trading wallet
+type WalletBalances struct {
+	Lamports uint64
+	Tokens   map[string]*tokenaccount.TokenAccount // By token name, nil if the account does not exist
+}
+
+// fetchWalletBalances reads the wallet's SOL balance and its associated
+// token account of every configured token
+func fetchWalletBalances(ctx context.Context, client *rpc.Client, wallet solana.PublicKey, tokens map[string]TokenConfig) (*WalletBalances, error) {
+	balance, err := client.GetBalance(ctx, wallet, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch balance of %s: %v", wallet, err)
+	}
+
+	names := make([]string, 0, len(tokens))
+	for name := range tokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	accounts := make([]solana.PublicKey, 0, len(names))
+	for _, name := range names {
+		mint, err := solana.PublicKeyFromBase58(tokens[name].Mint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mint %s: %v", tokens[name].Mint, err)
+		}
+		ata, err := associatedTokenAddress(wallet, mint, tokenProgram(tokens, tokens[name].Mint))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive token account for %s: %v", name, err)
+		}
+		accounts = append(accounts, ata)
+	}
+
+	result, err := client.GetMultipleAccountsWithOpts(ctx, accounts, &rpc.GetMultipleAccountsOpts{
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch token accounts of %s: %v", wallet, err)
+	}
+
+	balances := &WalletBalances{
+		Lamports: balance.Value,
+		Tokens:   make(map[string]*tokenaccount.TokenAccount, len(names)),
+	}
+	for i, name := range names {
+		balances.Tokens[name] = nil
+		if i >= len(result.Value) || result.Value[i] == nil {
+			continue
+		}
+		var account tokenaccount.TokenAccount
+		if err := account.Decode(result.Value[i].Data.GetBinary()); err != nil {
+			return nil, fmt.Errorf("failed to decode %s token account: %v", name, err)
+		}
+		account.SetDecimals(tokens[name].Decimals)
+		balances.Tokens[name] = &account
+	}
+	return balances, nil
+}
+
+// reportWalletBalances logs the wallet's balances at startup, warning about
+// what would keep it from trading
+func reportWalletBalances(ctx context.Context, client *rpc.Client, wallet solana.PublicKey, cfg *Config) error {
+	balances, err := fetchWalletBalances(ctx, client, wallet, cfg.Tokens)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wallet %s: %.9f SOL", wallet, float64(balances.Lamports)/float64(solana.LAMPORTS_PER_SOL))
+	// The wallet must at least afford one transaction of the longest cycle
+	dexes := make([]string, cfg.MaxCycleHops)
+	for i := range dexes {
+		dexes[i] = "default"
+	}
+	minLamports := cfg.Costs.CostLamports(cfg.Costs.ComputeUnits(dexes))
+	if balances.Lamports < minLamports {
+		log.Printf("Warning: wallet SOL balance is below the %d lamports one transaction costs", minLamports)
+	}
+
+	for _, name := range sortedTokenNames(balances.Tokens) {
+		account := balances.Tokens[name]
+		switch {
+		case account == nil:
+			log.Printf("Wallet %s: no token account, created by the first transaction using it", name)
+		case account.State != "initialized":
+			log.Printf("Warning: wallet %s token account is %s", name, account.State)
+		default:
+			log.Printf("Wallet %s: %s", name, account.TokenAmount.UIAmountString)
+		}
+	}
+	return nil
+}
+
+// sortedTokenNames returns the token names of the balances in order
+func sortedTokenNames(tokens map[string]*tokenaccount.TokenAccount) []string {
+	names := make([]string, 0, len(tokens))
+	for name := range tokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// confirmSignature polls the status of a transaction until it is confirmed,
+// fails or timeout passes
+func confirmSignature(ctx context.Context, client *rpc.Client, signature solana.Signature, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		out, err := client.GetSignatureStatuses(ctx, false, signature)
+		if err == nil && len(out.Value) == 1 && out.Value[0] != nil {
+			status := out.Value[0]
+			if status.Err != nil {
+				return fmt.Errorf("transaction %s failed: %v", signature, status.Err)
+			}
+			if status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed ||
+				status.ConfirmationStatus == rpc.ConfirmationStatusFinalized {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("transaction %s not confirmed within %v", signature, timeout)
+		case <-ticker.C:
+		}
+	}
+}